@@ -0,0 +1,70 @@
+// Package analytics provides an Exporter Component that batches the analytics events,
+// such as connects, match results and the custom game events, to a pluggable Sink,
+// with buffering, retries and the drop metrics.
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type (
+	// Event is an analytics event, Name identifies its kind, such as "client_connected" or "match_finished".
+	Event struct {
+		Time       time.Time              `json:"time"`
+		Name       string                 `json:"name"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+	}
+
+	// Sink delivers a batch of Events to a destination, such as a Kafka topic or a data warehouse loader.
+	// A non-nil error makes the Exporter retry the whole batch, so Send should be idempotent or the destination
+	// should tolerate duplicates. Send must not retain events after it returns.
+	Sink interface {
+		Send(ctx context.Context, events []Event) error
+	}
+
+	// SinkFunc is an adapter to use a function as a Sink.
+	SinkFunc func(ctx context.Context, events []Event) error
+
+	// Emitter accepts the Events to be sent, such as an Exporter.
+	// Emit is invoked on the paths serving clients, so it must not block.
+	Emitter interface {
+		Emit(ev Event)
+	}
+
+	// WriterSink writes each Event as a line of JSON to an io.Writer, such as a file tailed by a log shipper.
+	WriterSink struct {
+		mu  sync.Mutex // mu guards enc.
+		enc *json.Encoder
+	}
+)
+
+// NewEvent creates an Event named name at now with properties.
+func NewEvent(name string, properties map[string]interface{}) Event {
+	return Event{Time: time.Now(), Name: name, Properties: properties}
+}
+
+// Send calls f(ctx, events).
+func (f SinkFunc) Send(ctx context.Context, events []Event) error {
+	return f(ctx, events)
+}
+
+// NewWriterSink creates a WriterSink writing to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{enc: json.NewEncoder(w)}
+}
+
+// Send writes events as lines of JSON.
+func (s *WriterSink) Send(_ context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		if err := s.enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
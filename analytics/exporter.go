@@ -0,0 +1,266 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBufferSize    = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = 1 * time.Second
+)
+
+var (
+	errExporterRunning = errors.New("ppcserver: analytics Exporter is already started")
+	errExporterClosed  = errors.New("ppcserver: analytics Exporter is shut down")
+)
+
+var _ Emitter = (*Exporter)(nil)
+
+type (
+	// Option is a function to apply various configurations to customize an Exporter.
+	Option func(e *Exporter)
+
+	// Exporter is a Component that buffers the emitted Events and sends them to a Sink in batches,
+	// a batch is sent when it reaches the batch size or when the flush interval elapses, whichever first.
+	// A failed batch is retried with exponential backoff, and dropped after the retries are exhausted.
+	Exporter struct {
+		numSent    int64 // numSent is accessed atomically, keep the 64-bit fields first for the alignment on 32-bit platforms.
+		numDropped int64 // numDropped is accessed atomically.
+		numFailed  int64 // numFailed is accessed atomically.
+
+		sink          Sink
+		bufferSize    int
+		batchSize     int
+		flushInterval time.Duration
+		maxRetries    int
+		retryBackoff  time.Duration
+
+		eventCh chan Event
+		pending []Event
+
+		mu      sync.RWMutex  // mu guards the fields below, Emit holds it for reading so that no Event slips past Shutdown.
+		doneCh  chan struct{} // doneCh is closed when the running Start exits, after which pending is owned by Shutdown.
+		running bool
+		closed  bool // closed is set by Shutdown, after which Emit drops every Event and Start fails.
+	}
+)
+
+// NewExporter creates a new Exporter sending to sink.
+func NewExporter(sink Sink, opts ...Option) *Exporter {
+	e := &Exporter{
+		sink:          sink,
+		bufferSize:    defaultBufferSize,
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+		maxRetries:    3,
+		retryBackoff:  100 * time.Millisecond,
+	}
+
+	// Apply opts to customize Exporter.
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.eventCh = make(chan Event, e.bufferSize)
+	return e
+}
+
+// Emit buffers ev to be sent, it never blocks. If the buffer is full, or the Exporter is shut down,
+// ev is dropped and counted by NumDropped.
+// A zero ev.Time is set to now. Emit is safe for concurrent use.
+func (e *Exporter) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		atomic.AddInt64(&e.numDropped, 1)
+		return
+	}
+	select {
+	case e.eventCh <- ev:
+	default:
+		atomic.AddInt64(&e.numDropped, 1)
+	}
+}
+
+// Start sends the emitted Events in batches and blocks until ctx is done.
+// The Events still buffered then are sent by Shutdown.
+// Start fails if it is already running or the Exporter is shut down, it may be invoked again once it returns,
+// such as when the Component is restarted.
+func (e *Exporter) Start(ctx context.Context) error {
+	e.mu.Lock()
+	switch {
+	case e.closed:
+		e.mu.Unlock()
+		return errExporterClosed
+	case e.running:
+		e.mu.Unlock()
+		return errExporterRunning
+	}
+	e.running = true
+	doneCh := make(chan struct{})
+	e.doneCh = doneCh
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+		close(doneCh)
+	}()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-e.eventCh:
+			e.pending = append(e.pending, ev)
+			if len(e.pending) >= e.batchSize {
+				e.flush(ctx)
+			}
+		case <-ticker.C:
+			e.flush(ctx)
+		}
+	}
+}
+
+// Shutdown waits for Start to return, and then sends the Events still buffered,
+// until they are all sent or ctx is done.
+// The Events emitted after Shutdown is invoked are dropped and counted by NumDropped.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	doneCh := e.doneCh
+	e.mu.Unlock()
+
+	if doneCh != nil {
+		select {
+		case <-doneCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		select {
+		case ev := <-e.eventCh:
+			e.pending = append(e.pending, ev)
+			if len(e.pending) >= e.batchSize {
+				e.flush(ctx)
+			}
+		default:
+			e.flush(ctx)
+			if err := ctx.Err(); err != nil {
+				// The Events not sent before the deadline are lost.
+				atomic.AddInt64(&e.numFailed, int64(len(e.pending)+len(e.eventCh)))
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// NumSent returns the number of Events sent to the Sink since the start.
+func (e *Exporter) NumSent() int64 {
+	return atomic.LoadInt64(&e.numSent)
+}
+
+// NumDropped returns the number of Events dropped since the start as the buffer is full.
+func (e *Exporter) NumDropped() int64 {
+	return atomic.LoadInt64(&e.numDropped)
+}
+
+// NumFailed returns the number of Events dropped since the start as the Sink still fails after the retries.
+func (e *Exporter) NumFailed() int64 {
+	return atomic.LoadInt64(&e.numFailed)
+}
+
+// flush sends the pending Events as a batch with the retries, and then starts a new batch,
+// unless ctx is done before the batch is sent, in which case the batch stays pending.
+func (e *Exporter) flush(ctx context.Context) {
+	if len(e.pending) == 0 {
+		return
+	}
+	batch := e.pending
+	e.pending = nil // Not reusing the backing array, as the Sink may still refer to it on a timeout.
+
+	backoff := e.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := e.sink.Send(ctx, batch)
+		if err == nil {
+			atomic.AddInt64(&e.numSent, int64(len(batch)))
+			return
+		}
+		if ctx.Err() != nil {
+			// Keep the batch for Shutdown to send, it is not the Sink to blame.
+			e.pending = append(batch, e.pending...)
+			return
+		}
+		if attempt >= e.maxRetries {
+			log.Printf("ppcserver: analytics Sink.Send() error, %d events dropped: %v", len(batch), err)
+			atomic.AddInt64(&e.numFailed, int64(len(batch)))
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		backoff *= 2
+	}
+}
+
+// WithBufferSize is an Option to set the number of Events buffered before Emit starts dropping, default is 10000.
+// An n <= 0 applies the default.
+func WithBufferSize(n int) Option {
+	return func(e *Exporter) {
+		if n <= 0 {
+			n = defaultBufferSize
+		}
+		e.bufferSize = n
+	}
+}
+
+// WithBatch is an Option to set the maximum number of Events per batch and the interval of sending a partial batch,
+// default is 100 Events and 1 second. A size <= 0 or a flushInterval <= 0 applies the default.
+func WithBatch(size int, flushInterval time.Duration) Option {
+	return func(e *Exporter) {
+		if size <= 0 {
+			size = defaultBatchSize
+		}
+		if flushInterval <= 0 {
+			flushInterval = defaultFlushInterval
+		}
+		e.batchSize = size
+		e.flushInterval = flushInterval
+	}
+}
+
+// WithRetry is an Option to set the number of retries of a failed batch and the initial backoff between them,
+// which doubles on every retry, default is 3 retries from 100 milliseconds.
+// A negative maxRetries or backoff is treated as 0, no retry or no wait between the retries.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(e *Exporter) {
+		if maxRetries < 0 {
+			maxRetries = 0
+		}
+		if backoff < 0 {
+			backoff = 0
+		}
+		e.maxRetries = maxRetries
+		e.retryBackoff = backoff
+	}
+}
@@ -0,0 +1,220 @@
+package analytics_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/analytics"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records the batches it receives, and fails the first failures calls of Send.
+type recordingSink struct {
+	mu       sync.Mutex
+	batches  [][]analytics.Event
+	calls    int
+	failures int
+	sentCh   chan struct{}
+}
+
+func newRecordingSink(failures int) *recordingSink {
+	return &recordingSink{failures: failures, sentCh: make(chan struct{}, 64)}
+}
+
+func (s *recordingSink) Send(_ context.Context, events []analytics.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("sink unavailable")
+	}
+	s.batches = append(s.batches, append([]analytics.Event(nil), events...))
+	s.sentCh <- struct{}{}
+	return nil
+}
+
+func (s *recordingSink) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, b := range s.batches {
+		sizes[i] = len(b)
+	}
+	return sizes
+}
+
+func (s *recordingSink) numCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func (s *recordingSink) waitSent(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.sentCh:
+	case <-time.After(time.Second):
+		t.Fatal("no batch is sent")
+	}
+}
+
+// startExporter starts e and returns a func shutting it down as the Server does, by canceling Start's ctx first.
+func startExporter(t *testing.T, e *analytics.Exporter) (shutdown func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- e.Start(ctx) }()
+	return func() error {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+		defer cancelShutdown()
+		return e.Shutdown(shutdownCtx)
+	}
+}
+
+func emitN(e *analytics.Exporter, n int) {
+	for i := 0; i < n; i++ {
+		e.Emit(analytics.NewEvent("test", map[string]interface{}{"i": i}))
+	}
+}
+
+func TestExporterBatchSize(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(sink, analytics.WithBatch(3, time.Hour))
+	shutdown := startExporter(t, e)
+	defer shutdown()
+
+	emitN(e, 7)
+	sink.waitSent(t)
+	sink.waitSent(t)
+	if got := sink.batchSizes(); len(got) != 2 || got[0] != 3 || got[1] != 3 {
+		t.Fatalf("batch sizes = %v, want [3 3]", got)
+	}
+}
+
+func TestExporterFlushInterval(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(sink, analytics.WithBatch(100, 10*time.Millisecond))
+	shutdown := startExporter(t, e)
+	defer shutdown()
+
+	emitN(e, 2)
+	sink.waitSent(t)
+	if got := sink.batchSizes(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("batch sizes = %v, want [2]", got)
+	}
+}
+
+func TestExporterRetry(t *testing.T) {
+	sink := newRecordingSink(2)
+	e := analytics.NewExporter(sink, analytics.WithBatch(2, time.Hour), analytics.WithRetry(3, time.Millisecond))
+	shutdown := startExporter(t, e)
+	defer shutdown()
+
+	emitN(e, 2)
+	sink.waitSent(t)
+	if got := sink.numCalls(); got != 3 {
+		t.Fatalf("Send() calls = %d, want 3", got)
+	}
+	if got := e.NumSent(); got != 2 {
+		t.Fatalf("NumSent() = %d, want 2", got)
+	}
+	if got := e.NumFailed(); got != 0 {
+		t.Fatalf("NumFailed() = %d, want 0", got)
+	}
+}
+
+func TestExporterRetryExhausted(t *testing.T) {
+	sink := newRecordingSink(100)
+	e := analytics.NewExporter(sink, analytics.WithBatch(2, time.Hour), analytics.WithRetry(2, time.Millisecond))
+	shutdown := startExporter(t, e)
+
+	emitN(e, 2)
+	deadline := time.Now().Add(time.Second)
+	for e.NumFailed() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("NumFailed() = %d, want 2", e.NumFailed())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.numCalls(); got != 3 {
+		t.Fatalf("Send() calls = %d, want 3", got)
+	}
+	if got := e.NumSent(); got != 0 {
+		t.Fatalf("NumSent() = %d, want 0", got)
+	}
+}
+
+func TestExporterShutdownFlushes(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(sink, analytics.WithBatch(100, time.Hour))
+	shutdown := startExporter(t, e)
+
+	emitN(e, 5)
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.NumSent(); got != 5 {
+		t.Fatalf("NumSent() = %d, want 5", got)
+	}
+}
+
+func TestExporterEmitAfterShutdown(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(sink)
+	shutdown := startExporter(t, e)
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	emitN(e, 3)
+	if got := e.NumDropped(); got != 3 {
+		t.Fatalf("NumDropped() = %d, want 3", got)
+	}
+	if err := e.Start(context.Background()); err == nil {
+		t.Fatal("Start() after Shutdown() succeeds")
+	}
+}
+
+func TestExporterStartTwice(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(sink, analytics.WithBatch(1, time.Hour))
+	shutdown := startExporter(t, e)
+	defer shutdown()
+
+	// Wait for a batch, so the first Start is known to be running.
+	emitN(e, 1)
+	sink.waitSent(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.Start(ctx); err == nil {
+		t.Fatal("Start() while running succeeds")
+	}
+}
+
+func TestExporterInvalidOptions(t *testing.T) {
+	sink := newRecordingSink(0)
+	e := analytics.NewExporter(
+		sink,
+		analytics.WithBufferSize(0),
+		analytics.WithBatch(0, 0),
+		analytics.WithRetry(-1, -time.Second),
+	)
+	shutdown := startExporter(t, e)
+
+	emitN(e, 3)
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.NumSent(); got != 3 {
+		t.Fatalf("NumSent() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Package audit provides structured audit events of the administrative and security actions,
+// such as kicks and bans, and the pluggable sinks to deliver them for compliance and incident investigation.
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// EventTypeClientKicked is emitted when the server closes a client for misbehaving, such as being a slow consumer.
+	EventTypeClientKicked EventType = "client_kicked"
+	// EventTypeClientDisconnected is emitted when a client is disconnected for any reason, including a kick,
+	// with the reason such as "peer closed" or "read timeout".
+	EventTypeClientDisconnected EventType = "client_disconnected"
+	// EventTypeConnRejected is emitted when a connection attempt from a banned peer is rejected.
+	EventTypeConnRejected EventType = "conn_rejected"
+	// EventTypeUserBanned is emitted when a user is banned.
+	EventTypeUserBanned EventType = "user_banned"
+	// EventTypeUserUnbanned is emitted when the ban of a user is lifted.
+	EventTypeUserUnbanned EventType = "user_unbanned"
+	// EventTypeIPBanned is emitted when an IP prefix is banned.
+	EventTypeIPBanned EventType = "ip_banned"
+	// EventTypeIPUnbanned is emitted when the ban of an IP prefix is lifted.
+	EventTypeIPUnbanned EventType = "ip_unbanned"
+)
+
+type (
+	// EventType identifies the action an Event records.
+	EventType string
+
+	// Event is a structured record of an administrative or security action.
+	Event struct {
+		Time time.Time `json:"time"`
+		Type EventType `json:"type"`
+		// Actor is who performs the action, such as an admin user, see WithActor. Empty means the server itself.
+		Actor string `json:"actor,omitempty"`
+		// Target is what the action is performed on, such as a user ID, an IP prefix or a client ID.
+		Target string `json:"target,omitempty"`
+		// Reason is why the action is performed, if known.
+		Reason string `json:"reason,omitempty"`
+		// Fields are the additional details specific to Type.
+		Fields map[string]string `json:"fields,omitempty"`
+	}
+
+	// Sink delivers Events to a destination, such as a file or a webhook.
+	// Emit is invoked on the paths serving clients, so it should not block for long,
+	// a Sink with a slow destination should buffer and deliver in the background like WebhookSink.
+	// Implementations must be safe for concurrent use.
+	Sink interface {
+		Emit(ctx context.Context, e Event) error
+	}
+
+	// SinkFunc is an adapter to use a function as a Sink.
+	SinkFunc func(ctx context.Context, e Event) error
+
+	actorKey struct{}
+)
+
+// Emit calls f(ctx, e).
+func (f SinkFunc) Emit(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// WithActor returns a copy of ctx carrying actor, which is recorded as Event.Actor by the audited actions using ctx,
+// such as an admin API handler passing its request context to an audited banlist.Store.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, or an empty string if none.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// NewEvent creates an Event of type t on target at now, with the actor carried by ctx.
+func NewEvent(ctx context.Context, t EventType, target, reason string) Event {
+	return Event{
+		Time:   time.Now(),
+		Type:   t,
+		Actor:  ActorFrom(ctx),
+		Target: target,
+		Reason: reason,
+	}
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/banlist"
+	"log"
+	"net/netip"
+	"time"
+)
+
+// auditedStore is a banlist.Store emitting an Event to a Sink for each successful ban and unban.
+type auditedStore struct {
+	banlist.Store
+	sink Sink
+}
+
+// NewAuditedStore wraps store so that every successful ban and unban is emitted to sink,
+// with the actor carried by the ctx passed in, see WithActor.
+func NewAuditedStore(store banlist.Store, sink Sink) banlist.Store {
+	return &auditedStore{Store: store, sink: sink}
+}
+
+func (s *auditedStore) BanUser(ctx context.Context, userID string, expiresAt time.Time) error {
+	if err := s.Store.BanUser(ctx, userID, expiresAt); err != nil {
+		return err
+	}
+	s.emit(ctx, EventTypeUserBanned, userID, expiresAt)
+	return nil
+}
+
+func (s *auditedStore) UnbanUser(ctx context.Context, userID string) error {
+	if err := s.Store.UnbanUser(ctx, userID); err != nil {
+		return err
+	}
+	s.emit(ctx, EventTypeUserUnbanned, userID, time.Time{})
+	return nil
+}
+
+func (s *auditedStore) BanIP(ctx context.Context, prefix netip.Prefix, expiresAt time.Time) error {
+	if err := s.Store.BanIP(ctx, prefix, expiresAt); err != nil {
+		return err
+	}
+	s.emit(ctx, EventTypeIPBanned, prefix.String(), expiresAt)
+	return nil
+}
+
+func (s *auditedStore) UnbanIP(ctx context.Context, prefix netip.Prefix) error {
+	if err := s.Store.UnbanIP(ctx, prefix); err != nil {
+		return err
+	}
+	s.emit(ctx, EventTypeIPUnbanned, prefix.String(), time.Time{})
+	return nil
+}
+
+// emit emits an Event of type t on target, with expiresAt recorded unless it is zero.
+// An error from the Sink is only logged, as the ban itself has succeeded.
+func (s *auditedStore) emit(ctx context.Context, t EventType, target string, expiresAt time.Time) {
+	e := NewEvent(ctx, t, target, "")
+	if !expiresAt.IsZero() {
+		e.Fields = map[string]string{"expires_at": expiresAt.Format(time.RFC3339)}
+	}
+	if err := s.sink.Emit(ctx, e); err != nil {
+		log.Println("ppcserver: audit Sink.Emit() error:", err)
+	}
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhookBufferSize is the number of Events WebhookSink buffers for the delivery before dropping.
+const webhookBufferSize = 1024
+
+var (
+	ErrSinkFull   = errors.New("ppcserver: audit sink buffer is full")
+	ErrSinkClosed = errors.New("ppcserver: audit sink is closed")
+)
+
+// defaultWebhookClient is used by NewWebhookSink without a client,
+// its timeout keeps an unresponsive webhook from stalling the delivery of the following Events forever.
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookSink posts each Event as JSON to a URL in the background, so Emit never blocks on the network.
+// An Event failing to deliver is logged and dropped, it is not retried.
+type WebhookSink struct {
+	url     string
+	client  *http.Client
+	eventCh chan Event
+	doneCh  chan struct{} // doneCh is closed when the delivering goroutine exits.
+	mu      sync.RWMutex  // mu guards closed, Emit holds it for reading so that eventCh is not closed while sending.
+	closed  bool
+}
+
+// NewWebhookSink creates a WebhookSink posting to url with client,
+// a client with a 10 seconds timeout is used if client is nil.
+// Close the WebhookSink to deliver the buffered Events and stop the delivering goroutine.
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	s := &WebhookSink{
+		url:     url,
+		client:  client,
+		eventCh: make(chan Event, webhookBufferSize),
+		doneCh:  make(chan struct{}),
+	}
+	go s.deliverLoop()
+	return s
+}
+
+// Emit queues e for the delivery, or returns ErrSinkFull with e dropped if the buffer is full,
+// or ErrSinkClosed if the WebhookSink is closed.
+func (s *WebhookSink) Emit(_ context.Context, e Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.eventCh <- e:
+		return nil
+	default:
+		return ErrSinkFull
+	}
+}
+
+// Close stops accepting Events and blocks until the buffered ones are delivered or ctx is done.
+// Emit returns ErrSinkClosed after Close, it is safe to call Close more than once.
+func (s *WebhookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.eventCh)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookSink) deliverLoop() {
+	defer close(s.doneCh)
+	for e := range s.eventCh {
+		if err := s.deliver(e); err != nil {
+			log.Println("ppcserver: WebhookSink.deliver() error:", err)
+		}
+	}
+}
+
+func (s *WebhookSink) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ppcserver: webhook responds %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []audit.Event
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				var e audit.Event
+				if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mu.Lock()
+				events = append(events, e)
+				mu.Unlock()
+			},
+		),
+	)
+	defer server.Close()
+
+	ctx := context.Background()
+	s := audit.NewWebhookSink(server.URL, nil)
+	if err := s.Emit(ctx, audit.NewEvent(ctx, audit.EventTypeClientKicked, "01J", "spam")); err != nil {
+		t.Fatal(err)
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.Close(closeCtx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].Target != "01J" || events[0].Reason != "spam" {
+		t.Fatalf("delivered events = %+v, want the emitted one", events)
+	}
+}
+
+func TestWebhookSinkEmitAfterClose(t *testing.T) {
+	ctx := context.Background()
+	s := audit.NewWebhookSink("http://127.0.0.1:0", nil)
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if err := s.Emit(ctx, audit.NewEvent(ctx, audit.EventTypeClientKicked, "01J", "")); !errors.Is(err, audit.ErrSinkClosed) {
+		t.Fatalf("Emit() after Close() error = %v, want ErrSinkClosed", err)
+	}
+}
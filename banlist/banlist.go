@@ -0,0 +1,33 @@
+// Package banlist provides storage for banned user IDs and IP/CIDR ranges.
+// A Store is consulted by connectors to reject banned peers before a connection is accepted.
+package banlist
+
+import (
+	"context"
+	"net/netip"
+	"time"
+)
+
+// Store abstracts the storage of bans, so that it can be backed by in-memory maps or by a shared remote storage.
+// A zero expiresAt means the ban never expires.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// BanUser should ban userID until expiresAt.
+	BanUser(ctx context.Context, userID string, expiresAt time.Time) error
+	// UnbanUser should lift the ban of userID, it is not an error if userID is not banned.
+	UnbanUser(ctx context.Context, userID string) error
+	// BanIP should ban all the addresses within prefix until expiresAt.
+	// Use a single-address prefix such as "203.0.113.7/32" to ban one IP.
+	BanIP(ctx context.Context, prefix netip.Prefix, expiresAt time.Time) error
+	// UnbanIP should lift the ban of prefix, it is not an error if prefix is not banned.
+	UnbanIP(ctx context.Context, prefix netip.Prefix) error
+	// IsUserBanned should report whether userID is currently banned.
+	IsUserBanned(ctx context.Context, userID string) (bool, error)
+	// IsIPBanned should report whether ip is within any currently banned prefix.
+	IsIPBanned(ctx context.Context, ip netip.Addr) (bool, error)
+}
+
+// expired reports whether a ban that expires at expiresAt is no longer in effect at now.
+func expired(expiresAt, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
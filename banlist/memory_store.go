@@ -0,0 +1,130 @@
+package banlist
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store, the bans are lost when the process exits.
+// Expired bans are evicted lazily when they are looked up.
+// IsIPBanned looks up each banned prefix length once, instead of scanning all the banned prefixes.
+type MemoryStore struct {
+	mu         sync.RWMutex               // mu guards the fields below.
+	users      map[string]time.Time       // users maps the banned user ID to its expiry.
+	prefixes   map[netip.Prefix]time.Time // prefixes maps the banned masked IP prefix to its expiry.
+	prefixBits map[int]int                // prefixBits counts the banned prefixes by their length.
+}
+
+// NewMemoryStore creates a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:      make(map[string]time.Time),
+		prefixes:   make(map[netip.Prefix]time.Time),
+		prefixBits: make(map[int]int),
+	}
+}
+
+func (s *MemoryStore) BanUser(_ context.Context, userID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) UnbanUser(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, userID)
+	return nil
+}
+
+func (s *MemoryStore) BanIP(_ context.Context, prefix netip.Prefix, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Masked so that "10.0.0.1/8" and "10.0.0.0/8" are stored as the same key.
+	prefix = prefix.Masked()
+	if _, ok := s.prefixes[prefix]; !ok {
+		s.prefixBits[prefix.Bits()]++
+	}
+	s.prefixes[prefix] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) UnbanIP(_ context.Context, prefix netip.Prefix) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletePrefix(prefix.Masked())
+	return nil
+}
+
+func (s *MemoryStore) IsUserBanned(_ context.Context, userID string) (bool, error) {
+	s.mu.RLock()
+	expiresAt, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	if expired(expiresAt, time.Now()) {
+		s.mu.Lock()
+		// Re-check under the write lock since the ban may be renewed in between.
+		if expiresAt, ok := s.users[userID]; ok && expired(expiresAt, time.Now()) {
+			delete(s.users, userID)
+		}
+		s.mu.Unlock()
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) IsIPBanned(_ context.Context, ip netip.Addr) (bool, error) {
+	// Unmap so that an IPv4-mapped IPv6 address such as "::ffff:10.0.0.1" matches IPv4 prefixes.
+	ip = ip.Unmap().WithZone("")
+	now := time.Now()
+
+	s.mu.RLock()
+	banned := false
+	var expiredPrefixes []netip.Prefix
+	for bits := range s.prefixBits {
+		if bits < 0 || bits > ip.BitLen() {
+			continue
+		}
+		prefix := netip.PrefixFrom(ip, bits).Masked()
+		expiresAt, ok := s.prefixes[prefix]
+		if !ok {
+			continue
+		}
+		if expired(expiresAt, now) {
+			expiredPrefixes = append(expiredPrefixes, prefix)
+			continue
+		}
+		banned = true
+		break
+	}
+	s.mu.RUnlock()
+
+	if len(expiredPrefixes) > 0 {
+		s.mu.Lock()
+		for _, prefix := range expiredPrefixes {
+			// Re-check under the write lock since the ban may be renewed in between.
+			if expiresAt, ok := s.prefixes[prefix]; ok && expired(expiresAt, time.Now()) {
+				s.deletePrefix(prefix)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return banned, nil
+}
+
+// deletePrefix deletes the masked prefix, s.mu must be held.
+func (s *MemoryStore) deletePrefix(prefix netip.Prefix) {
+	if _, ok := s.prefixes[prefix]; !ok {
+		return
+	}
+	delete(s.prefixes, prefix)
+	if s.prefixBits[prefix.Bits()]--; s.prefixBits[prefix.Bits()] == 0 {
+		delete(s.prefixBits, prefix.Bits())
+	}
+}
@@ -0,0 +1,133 @@
+package banlist_test
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/banlist"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func isIPBanned(t *testing.T, s *banlist.MemoryStore, ip string) bool {
+	t.Helper()
+	banned, err := s.IsIPBanned(context.Background(), netip.MustParseAddr(ip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return banned
+}
+
+func banIP(t *testing.T, s *banlist.MemoryStore, prefix string, expiresAt time.Time) {
+	t.Helper()
+	if err := s.BanIP(context.Background(), netip.MustParsePrefix(prefix), expiresAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unbanIP(t *testing.T, s *banlist.MemoryStore, prefix string) {
+	t.Helper()
+	if err := s.UnbanIP(context.Background(), netip.MustParsePrefix(prefix)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryStoreIPPrefixes(t *testing.T) {
+	s := banlist.NewMemoryStore()
+	banIP(t, s, "10.1.0.0/16", time.Time{})
+	banIP(t, s, "203.0.113.7/32", time.Time{})
+	banIP(t, s, "2001:db8::/48", time.Time{})
+
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"10.1.2.3", true},
+		{"10.2.0.1", false},
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"::ffff:10.1.2.3", true},
+		{"::ffff:10.2.0.1", false},
+		{"2001:db8:0:ffff::1", true},
+		{"2001:db8:1::1", false},
+		{"fe80::1%eth0", false},
+	}
+	for _, tt := range tests {
+		if got := isIPBanned(t, s, tt.ip); got != tt.banned {
+			t.Errorf("IsIPBanned(%s) = %t, want %t", tt.ip, got, tt.banned)
+		}
+	}
+}
+
+func TestMemoryStoreUnbanIP(t *testing.T) {
+	s := banlist.NewMemoryStore()
+	banIP(t, s, "10.1.2.3/8", time.Time{})
+
+	// The prefix is masked, so it is unbanned by the same network written differently.
+	unbanIP(t, s, "10.0.0.0/8")
+	if isIPBanned(t, s, "10.1.2.3") {
+		t.Fatal("IsIPBanned() = true after UnbanIP()")
+	}
+	unbanIP(t, s, "192.0.2.0/24")
+}
+
+func TestMemoryStoreOverlappingPrefixes(t *testing.T) {
+	s := banlist.NewMemoryStore()
+	banIP(t, s, "10.0.0.0/8", time.Time{})
+	banIP(t, s, "10.1.0.0/16", time.Time{})
+
+	unbanIP(t, s, "10.1.0.0/16")
+	if !isIPBanned(t, s, "10.1.2.3") {
+		t.Fatal("IsIPBanned() = false while the enclosing /8 is still banned")
+	}
+	banIP(t, s, "10.1.0.0/16", time.Time{})
+	unbanIP(t, s, "10.0.0.0/8")
+	if !isIPBanned(t, s, "10.1.2.3") {
+		t.Fatal("IsIPBanned() = false while the enclosed /16 is still banned")
+	}
+	if isIPBanned(t, s, "10.2.0.1") {
+		t.Fatal("IsIPBanned() = true out of the remaining /16")
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := banlist.NewMemoryStore()
+	banIP(t, s, "10.0.0.0/8", time.Now().Add(-time.Second))
+	banIP(t, s, "10.1.0.0/16", time.Now().Add(time.Hour))
+
+	if !isIPBanned(t, s, "10.1.2.3") {
+		t.Fatal("IsIPBanned() = false within an unexpired prefix")
+	}
+	if isIPBanned(t, s, "10.2.0.1") {
+		t.Fatal("IsIPBanned() = true within an expired prefix only")
+	}
+
+	// Renewing an expired ban takes effect again.
+	banIP(t, s, "10.0.0.0/8", time.Time{})
+	if !isIPBanned(t, s, "10.2.0.1") {
+		t.Fatal("IsIPBanned() = false after the ban is renewed")
+	}
+}
+
+func TestMemoryStoreUsers(t *testing.T) {
+	ctx := context.Background()
+	s := banlist.NewMemoryStore()
+	if err := s.BanUser(ctx, "alice", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BanUser(ctx, "bob", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if banned, _ := s.IsUserBanned(ctx, "alice"); !banned {
+		t.Fatal("IsUserBanned(alice) = false")
+	}
+	if banned, _ := s.IsUserBanned(ctx, "bob"); banned {
+		t.Fatal("IsUserBanned(bob) = true after the ban expired")
+	}
+	if err := s.UnbanUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if banned, _ := s.IsUserBanned(ctx, "alice"); banned {
+		t.Fatal("IsUserBanned(alice) = true after UnbanUser()")
+	}
+}
@@ -0,0 +1,178 @@
+/**
+ * PPCClient is a minimal browser client of the ppcserver protocol.
+ *
+ * - Handshake: requests the protocol versions via the WebSocket subprotocols,
+ *   and exposes the one negotiated by the server as client.protocolVersion.
+ *   The build version of the application, if any, is sent in the "client_version" query parameter,
+ *   a server requiring a newer build replies with the "update_required" error and the client stops reconnecting.
+ * - Heartbeat: the server sends WebSocket pings, which browsers answer automatically,
+ *   so there is nothing to do on the client side.
+ * - Reconnect: reconnects with exponential backoff and jitter after the connection is lost,
+ *   or after the delay hinted by the "server_shutdown" control message when the server shuts down.
+ * - Region redirect: reconnects immediately to the host of its region hinted by the "redirect" control message.
+ * - Feature flags: keeps the flags pushed by the "feature_flags" control message in client.featureFlags.
+ */
+class PPCClient {
+    /**
+     * @param {string} url The WebSocket URL, such as "ws://localhost:8080/ws".
+     * @param {object} [options]
+     * @param {string[]} [options.protocolVersions] The protocol versions supported by the client, in the order of preference.
+     * @param {string} [options.clientVersion] The build version of the application, see WithClientVersionRange.
+     * @param {boolean} [options.reconnect] Whether to reconnect automatically, default is true.
+     * @param {number} [options.minReconnectDelayMs] The initial delay of the reconnect backoff.
+     * @param {number} [options.maxReconnectDelayMs] The maximum delay of the reconnect backoff.
+     * @param {boolean} [options.coalesced] Whether the server coalesces messages with newlines, see WithWriteCoalescing.
+     */
+    constructor(url, options = {}) {
+        this.url = url;
+        this.protocolVersions = options.protocolVersions || ["ppc.v1"];
+        this.clientVersion = options.clientVersion || "";
+        this.reconnect = options.reconnect !== false;
+        this.minReconnectDelayMs = options.minReconnectDelayMs || 500;
+        this.maxReconnectDelayMs = options.maxReconnectDelayMs || 30000;
+        this.coalesced = options.coalesced === true;
+
+        // The callbacks to be overridden by the application.
+        this.onopen = () => {};
+        this.onmessage = (data) => {};
+        this.onclose = (event) => {};
+        // onerror receives the structured error {code, message, retryable} sent by the server before it closes.
+        this.onerror = (error) => {};
+        // onfeatureflags receives client.featureFlags whenever the server pushes a change, see WithFeatureFlags.
+        this.onfeatureflags = (flags) => {};
+
+        this.protocolVersion = "";
+        this.socket = null;
+        this.closedByUser = false;
+        this.reconnectAttempts = 0;
+        // reconnects counts all the reconnections, reported to the server for its session analytics.
+        this.reconnects = 0;
+        this.shutdownNotice = null;
+        this.redirect = null;
+        this.fatalError = null;
+        this.reconnectTimer = null;
+        this.featureFlags = {};
+    }
+
+    /** connect opens the connection, it is called again automatically on reconnecting. */
+    connect() {
+        this.closedByUser = false;
+        this.shutdownNotice = null;
+        this.redirect = null;
+        this.fatalError = null;
+
+        const url = new URL(this.url);
+        if (this.clientVersion) {
+            url.searchParams.set("client_version", this.clientVersion);
+        }
+        if (this.reconnects > 0) {
+            url.searchParams.set("reconnects", String(this.reconnects));
+        }
+        const socket = new WebSocket(url.toString(), this.protocolVersions);
+        this.socket = socket;
+        socket.onopen = () => {
+            // An empty protocol means the server does not negotiate the version, which is "ppc.v1".
+            this.protocolVersion = socket.protocol || "ppc.v1";
+            this.reconnectAttempts = 0;
+            this.onopen();
+        };
+        socket.onmessage = (event) => {
+            const messages = this.coalesced && typeof event.data === "string" ? event.data.split("\n") : [event.data];
+            for (const message of messages) {
+                const data = this.decode(message);
+                if (data && data.type === "server_shutdown") {
+                    this.shutdownNotice = data;
+                    continue;
+                }
+                if (data && data.type === "redirect") {
+                    this.redirect = data;
+                    continue;
+                }
+                if (data && data.type === "feature_flags") {
+                    this.applyFeatureFlags(data);
+                    continue;
+                }
+                if (data && data.type === "error") {
+                    // Reconnecting does not help with an error that is not retryable, such as being banned.
+                    if (!data.error.retryable) {
+                        this.fatalError = data.error;
+                    }
+                    this.onerror(data.error);
+                    continue;
+                }
+                this.onmessage(data);
+            }
+        };
+        socket.onclose = (event) => {
+            this.onclose(event);
+            if (this.reconnect && !this.closedByUser && !this.fatalError) {
+                this.scheduleReconnect();
+            }
+        };
+    }
+
+    /** send encodes data in JSON if it is an object, and sends it to the server. */
+    send(data) {
+        this.socket.send(typeof data === "object" ? JSON.stringify(data) : data);
+    }
+
+    /** close closes the connection without reconnecting. */
+    close() {
+        this.closedByUser = true;
+        clearTimeout(this.reconnectTimer);
+        if (this.socket) {
+            this.socket.close();
+        }
+    }
+
+    applyFeatureFlags(message) {
+        // A snapshot replaces all the flags, the others carry only the changes.
+        const flags = message.snapshot ? {} : {...this.featureFlags};
+        Object.assign(flags, message.flags || {});
+        for (const name of message.deleted || []) {
+            delete flags[name];
+        }
+        this.featureFlags = flags;
+        this.onfeatureflags(flags);
+    }
+
+    decode(data) {
+        if (typeof data !== "string") {
+            return data;
+        }
+        try {
+            return JSON.parse(data);
+        } catch (e) {
+            return data;
+        }
+    }
+
+    scheduleReconnect() {
+        let delayMs;
+        const notice = this.shutdownNotice;
+        if (this.redirect) {
+            // The server serves another region closer to the client, reconnect there without a delay.
+            const url = new URL(this.url);
+            url.host = this.redirect.reconnect_host;
+            this.url = url.toString();
+            delayMs = 0;
+        } else if (notice) {
+            // Follow the hint of the server, the jitter spreads the reconnections of all the clients.
+            delayMs = notice.reconnect_delay_ms + Math.random() * notice.reconnect_jitter_ms;
+            if (notice.reconnect_host) {
+                const url = new URL(this.url);
+                url.host = notice.reconnect_host;
+                this.url = url.toString();
+            }
+        } else {
+            // Full jitter exponential backoff.
+            const capMs = Math.min(this.maxReconnectDelayMs, this.minReconnectDelayMs * 2 ** this.reconnectAttempts);
+            delayMs = Math.random() * capMs;
+            this.reconnectAttempts++;
+        }
+        this.reconnectTimer = setTimeout(() => {
+            this.reconnects++;
+            this.connect();
+        }, delayMs);
+    }
+}
@@ -0,0 +1,53 @@
+package connector
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"log"
+	"sync/atomic"
+)
+
+// emitAudit emits an audit.Event to sink if it is not nil, an error from the sink is only logged.
+func emitAudit(sink audit.Sink, t audit.EventType, target, reason string) {
+	emitAuditEvent(sink, audit.NewEvent(context.Background(), t, target, reason))
+}
+
+// emitAuditEvent emits e to sink if it is not nil, an error from the sink is only logged.
+func emitAuditEvent(sink audit.Sink, e audit.Event) {
+	if sink == nil {
+		return
+	}
+	if err := sink.Emit(context.Background(), e); err != nil {
+		log.Println("ppcserver: audit Sink.Emit() error:", err)
+	}
+}
+
+// auditKick emits an audit.EventTypeClientKicked of the Client to ClientOptions.AuditSink, see auditClient.
+// Only the first kick is audited, as more kicks may be detected before the Client is closed.
+func (c *Client) auditKick(reason string) {
+	if atomic.CompareAndSwapInt32(&c.kicked, 0, 1) {
+		c.auditClient(audit.EventTypeClientKicked, reason)
+	}
+}
+
+// auditClient emits an audit.Event of t of the Client to ClientOptions.AuditSink,
+// targeting the Client ID with the peer address in the "remote_addr" field.
+func (c *Client) auditClient(t audit.EventType, reason string) {
+	if c.opts.AuditSink == nil {
+		return
+	}
+	e := audit.NewEvent(context.Background(), t, c.id, reason)
+	if addr := c.remoteAddr(); addr != "" {
+		e.Fields = map[string]string{"remote_addr": addr}
+	}
+	emitAuditEvent(c.opts.AuditSink, e)
+}
+
+// remoteAddr returns the network address of the peer, or an empty string if the transport has no single connection.
+func (c *Client) remoteAddr() string {
+	conn := c.transport.NetConn()
+	if conn == nil {
+		return ""
+	}
+	return conn.RemoteAddr().String()
+}
@@ -0,0 +1,136 @@
+package connector_test
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+// auditRecorder is an audit.Sink handing the Events to the test through eventCh.
+type auditRecorder struct {
+	eventCh chan audit.Event
+}
+
+func newAuditRecorder() *auditRecorder {
+	return &auditRecorder{eventCh: make(chan audit.Event, 16)}
+}
+
+func (r *auditRecorder) Emit(_ context.Context, e audit.Event) error {
+	r.eventCh <- e
+	return nil
+}
+
+// next returns the next Event of type t, skipping the other types.
+func (r *auditRecorder) next(t *testing.T, eventType audit.EventType) audit.Event {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-r.eventCh:
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event is emitted", eventType)
+		}
+	}
+}
+
+func TestAuditDisconnect(t *testing.T) {
+	recorder := newAuditRecorder()
+	clientCh := make(chan *connector.Client, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithAuditSink(recorder),
+		connector.WithOnConnect(
+			func(c *connector.Client) {
+				clientCh <- c
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := <-clientCh
+	_ = conn.Close()
+
+	e := recorder.next(t, audit.EventTypeClientDisconnected)
+	if e.Target != c.ID() || e.Reason != connector.DisconnectReasonPeerClosed.String() {
+		t.Fatalf("event = %+v, want the Client %s disconnected for %q", e, c.ID(), connector.DisconnectReasonPeerClosed)
+	}
+	if e.Fields["remote_addr"] != conn.LocalAddr().String() {
+		t.Fatalf("remote_addr = %q, want %q", e.Fields["remote_addr"], conn.LocalAddr())
+	}
+}
+
+func TestAuditKick(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []connector.ClientOption
+		kick   func(c *connector.Client)
+		reason string
+	}{
+		{
+			name:   "closed by the application",
+			kick:   func(c *connector.Client) { _ = c.Close() },
+			reason: "closed by the application",
+		},
+		{
+			name:   "auth timeout",
+			opts:   []connector.ClientOption{connector.WithAuthTimeout(10 * time.Millisecond)},
+			kick:   func(c *connector.Client) {},
+			reason: "auth timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				recorder := newAuditRecorder()
+				clientCh := make(chan *connector.Client, 1)
+				s, err := ppctest.StartWebsocketServer(
+					connector.WithAuditSink(recorder),
+					connector.WithClientOptions(tt.opts...),
+					connector.WithOnConnect(
+						func(c *connector.Client) {
+							clientCh <- c
+						},
+					),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+
+				conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				c := <-clientCh
+				tt.kick(c)
+
+				e := recorder.next(t, audit.EventTypeClientKicked)
+				if e.Target != c.ID() || e.Reason != tt.reason {
+					t.Fatalf("event = %+v, want the Client %s kicked for %q", e, c.ID(), tt.reason)
+				}
+				// Closing the kicked Client again is not another kick.
+				_ = c.Close()
+				recorder.next(t, audit.EventTypeClientDisconnected)
+				select {
+				case e := <-recorder.eventCh:
+					t.Fatalf("unexpected event %+v", e)
+				default:
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/acme/autocert"
+	"log"
+	"net"
+	"net/http"
+)
+
+// newAutoTLSManager creates the autocert.Manager obtaining the certificates of Options.AutoTLSDomains from Let's Encrypt.
+// Only the listed domains are allowed, so a random SNI can not make the Manager request certificates for it.
+func newAutoTLSManager(opts *Options) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(opts.AutoTLSDomains...),
+		Cache:      opts.AutoTLSCache,
+	}
+}
+
+// startACMEChallenge listens on s.Addr and serves the ACME HTTP-01 challenge with s in the background,
+// until stop is called or s is shut down. s.Handler also redirects the other plain HTTP requests to HTTPS.
+// The TLS-ALPN-01 challenge is served by the TLS listener itself and needs nothing here.
+// A failure to listen is returned, so the connector fails to start instead of silently missing the challenge.
+func startACMEChallenge(s *http.Server) (stop func(), err error) {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("ppcserver: ACME challenge server net.Listen() error: %w", err)
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		// Serve returns net.ErrClosed once stop closes ln, after which s may serve again on a restart.
+		if err := s.Serve(ln); err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+			log.Println("ppcserver: WebsocketConnector ACME challenge server error:", err)
+		}
+	}()
+	return func() {
+		_ = ln.Close()
+		<-doneCh
+	}, nil
+}
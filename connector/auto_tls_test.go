@@ -0,0 +1,43 @@
+package connector_test
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAutoTLSChallengeListenError(t *testing.T) {
+	// Occupy the challenge address, so the ACME challenge server fails to listen.
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c := connector.NewWebsocketConnector(
+		connector.WithHTTPServer(&http.Server{}),
+		connector.WithHTTPServeMux(http.NewServeMux()),
+		connector.WithListener(ln),
+		connector.WithAutoTLS("example.com"),
+		connector.WithAutoTLSHTTPAddr(occupied.Addr().String()),
+	)
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Start(context.Background()) }()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("Start() error = nil, want the listen error of the ACME challenge server")
+		}
+	case <-time.After(time.Second):
+		_ = c.Shutdown(context.Background())
+		t.Fatal("Start() serves without the ACME challenge server")
+	}
+}
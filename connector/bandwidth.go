@@ -0,0 +1,123 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// BandwidthPolicyThrottle delays reading from a Client exceeding its bandwidth quota until the quota refills,
+	// which slows the peer down by the flow control of the underlying connection.
+	BandwidthPolicyThrottle BandwidthPolicy = iota
+	// BandwidthPolicyDisconnect closes a Client once it exceeds its bandwidth quota.
+	BandwidthPolicyDisconnect
+)
+
+var (
+	ErrBandwidthQuotaExceeded = errors.New("ppcserver: client bandwidth quota exceeded")
+
+	totalBytesIn  int64
+	totalBytesOut int64
+)
+
+type (
+	// BandwidthPolicy decides what happens to a Client exceeding its bandwidth quota.
+	BandwidthPolicy uint8
+
+	// bandwidthQuota limits the bytes per second read from a Client.
+	// bandwidthQuota is not safe for concurrent use, it is only accessed by readLoop.
+	bandwidthQuota struct {
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+)
+
+// TotalBytesIn returns the number of bytes read from all the Clients since the start.
+func TotalBytesIn() int64 {
+	return atomic.LoadInt64(&totalBytesIn)
+}
+
+// TotalBytesOut returns the number of bytes written to all the Clients since the start.
+func TotalBytesOut() int64 {
+	return atomic.LoadInt64(&totalBytesOut)
+}
+
+// BytesIn returns the number of bytes read from the Client.
+func (c *Client) BytesIn() int64 {
+	return atomic.LoadInt64(&c.bytesIn)
+}
+
+// BytesOut returns the number of bytes written to the Client.
+func (c *Client) BytesOut() int64 {
+	return atomic.LoadInt64(&c.bytesOut)
+}
+
+func (c *Client) addBytesIn(n int) {
+	atomic.AddInt64(&c.bytesIn, int64(n))
+	atomic.AddInt64(&totalBytesIn, int64(n))
+}
+
+func (c *Client) addBytesOut(n int) {
+	atomic.AddInt64(&c.bytesOut, int64(n))
+	atomic.AddInt64(&totalBytesOut, int64(n))
+}
+
+// newBandwidthQuota creates a bandwidthQuota, or returns nil if rate is not positive which means no quota.
+// burst is raised to rate if less, so that a second worth of bytes can be read at once.
+func newBandwidthQuota(rate, burst int) *bandwidthQuota {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < rate {
+		burst = rate
+	}
+	return &bandwidthQuota{rate: float64(rate), burst: float64(burst)}
+}
+
+// reserve refills the quota for the time elapsed since the last call and then takes n bytes from it,
+// the quota may go into debt, in which case the time until the debt is paid off is returned.
+func (q *bandwidthQuota) reserve(n int, now time.Time) time.Duration {
+	if q.last.IsZero() {
+		q.tokens = q.burst
+	} else if elapsed := now.Sub(q.last).Seconds(); elapsed > 0 {
+		q.tokens += elapsed * q.rate
+		if q.tokens > q.burst {
+			q.tokens = q.burst
+		}
+	}
+	q.last = now
+
+	q.tokens -= float64(n)
+	if q.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-q.tokens / q.rate * float64(time.Second))
+}
+
+// applyBandwidthQuota accounts n bytes read from the Client against quota by ClientOptions.BandwidthPolicy.
+// It blocks until the quota refills or ctx is done for BandwidthPolicyThrottle,
+// and returns ErrBandwidthQuotaExceeded for BandwidthPolicyDisconnect.
+func (c *Client) applyBandwidthQuota(ctx context.Context, quota *bandwidthQuota, n int) error {
+	delay := quota.reserve(n, time.Now())
+	if delay == 0 {
+		return nil
+	}
+	if c.opts.BandwidthPolicy == BandwidthPolicyDisconnect {
+		c.auditKick("bandwidth quota exceeded")
+		c.setDisconnectReason(DisconnectReasonKicked)
+		return ErrBandwidthQuotaExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
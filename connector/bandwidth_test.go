@@ -0,0 +1,142 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withBandwidthQuota is a ClientOption to set the bandwidth quota of the Client, see connector.WithBandwidthQuota.
+func withBandwidthQuota(rate, burst int, policy connector.BandwidthPolicy) connector.ClientOption {
+	return func(o *connector.ClientOptions) {
+		o.BandwidthQuota = rate
+		o.BandwidthBurst = burst
+		o.BandwidthPolicy = policy
+	}
+}
+
+func TestBandwidthAccounting(t *testing.T) {
+	transport := ppctest.NewTransport(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	clientCh := make(chan *connector.Client, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(
+			ctx, transport,
+			func(o *connector.ClientOptions) {
+				o.OnMessage = func(c *connector.Client, message []byte) {
+					_ = c.Write(append([]byte("echo "), message...))
+					clientCh <- c
+				}
+			},
+		)
+	}()
+	totalIn, totalOut := connector.TotalBytesIn(), connector.TotalBytesOut()
+
+	if err := transport.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	c := <-clientCh
+	if _, err := transport.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-errCh
+
+	if got := c.BytesIn(); got != 5 {
+		t.Fatalf("BytesIn() = %d, want 5", got)
+	}
+	if got := c.BytesOut(); got != 10 {
+		t.Fatalf("BytesOut() = %d, want 10", got)
+	}
+	if got := connector.TotalBytesIn() - totalIn; got < 5 {
+		t.Fatalf("TotalBytesIn() increased by %d, want at least 5", got)
+	}
+	if got := connector.TotalBytesOut() - totalOut; got < 10 {
+		t.Fatalf("TotalBytesOut() increased by %d, want at least 10", got)
+	}
+}
+
+func TestBandwidthQuotaDisconnect(t *testing.T) {
+	transport := ppctest.NewTransport(1)
+	clientCh := make(chan *connector.Client, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(
+			context.Background(), transport,
+			withBandwidthQuota(10, 10, connector.BandwidthPolicyDisconnect),
+			func(o *connector.ClientOptions) {
+				o.OnConnect = func(c *connector.Client) {
+					clientCh <- c
+				}
+			},
+		)
+	}()
+	c := <-clientCh
+
+	// The first message fits in the burst, and the second exceeds the quota.
+	for _, message := range []string{"0123456789", "x"} {
+		if err := transport.Send([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, connector.ErrBandwidthQuotaExceeded) {
+			t.Fatalf("StartClient() = %v, want %v", err, connector.ErrBandwidthQuotaExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the Client exceeding the quota is not disconnected")
+	}
+	if got := c.DisconnectReason(); got != connector.DisconnectReasonKicked {
+		t.Fatalf("DisconnectReason() = %s, want %s", got, connector.DisconnectReasonKicked)
+	}
+}
+
+func TestBandwidthQuotaThrottle(t *testing.T) {
+	const rate = 100
+	transport := ppctest.NewTransport(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	handledCh := make(chan time.Time, 2)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(
+			ctx, transport,
+			withBandwidthQuota(rate, rate, connector.BandwidthPolicyThrottle),
+			func(o *connector.ClientOptions) {
+				o.OnMessage = func(*connector.Client, []byte) {
+					handledCh <- time.Now()
+				}
+			},
+		)
+	}()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	// The first message takes the whole burst, and the second waits for 20 bytes to refill, which is 200ms.
+	for _, message := range []string{strings.Repeat("x", rate), strings.Repeat("x", rate/5)} {
+		if err := transport.Send([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := <-handledCh
+	select {
+	case second := <-handledCh:
+		if d := second.Sub(first); d < 150*time.Millisecond {
+			t.Fatalf("the second message is handled %s after the first, want it throttled for about 200ms", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the throttled message is never handled")
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("StartClient() = %v, want the throttled Client kept connected", err)
+	default:
+	}
+}
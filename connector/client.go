@@ -1,12 +1,17 @@
 package connector
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"golang.org/x/sync/errgroup"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -20,7 +25,12 @@ const (
 )
 
 var (
-	ErrExceedMaxClients = errors.New("ppcserver: exceed maximum number of clients")
+	ErrExceedMaxClients = NewError(ErrorCodeServerFull, "exceed maximum number of clients", true)
+	// ErrReadTimeout is returned by Transport.Read when nothing arrives from the peer within Options.ReadTimeout,
+	// which tells a dead peer apart from the other disconnect reasons.
+	ErrReadTimeout = errors.New("ppcserver: client read timeout")
+	// ErrClientClosed is returned by Client.Write once the Client is closed.
+	ErrClientClosed = errors.New("ppcserver: client closed")
 )
 
 type (
@@ -28,44 +38,128 @@ type (
 	ClientState uint8
 
 	// Client represents a Client connection to a server.
+	//
+	// All the exported methods of Client are safe for concurrent use, such as from the hooks, the HTTP handlers
+	// and the other Clients' goroutines. Internally, a Client is served by exactly one reading goroutine (readLoop)
+	// and one writing goroutine (writeLoop): Write only queues to writeLoop, which is the only goroutine writing
+	// to the transport until it exits. The remaining shared fields are either guarded by mu, accessed atomically,
+	// or set before the loops start and never mutated afterward, such as opts.
 	Client struct {
-		transport Transport
-		mu        sync.Mutex         // mu guards state.
-		state     ClientState        // state is guarded by mu.
-		cancelCtx context.CancelFunc // cancelCtx cancels the Client-level context that creates inside StartClient and result in Client.Close() being called.
-		readCh    chan []byte
-		writeCh   chan []byte // writeCh is the buffered channel of messages waiting to write to the transport.
+		bytesIn  int64 // bytesIn is accessed atomically, keep the 64-bit fields first for the alignment on 32-bit platforms.
+		bytesOut int64 // bytesOut is accessed atomically.
+		// droppedMessages is accessed atomically, see Client.DroppedMessages.
+		droppedMessages int64
+
+		writeQueueHighWaterMark int32 // writeQueueHighWaterMark is accessed atomically.
+		slowConsumer            int32 // slowConsumer is set to 1 atomically once the Client is detected as a slow consumer.
+		kicked                  int32 // kicked is set to 1 atomically once the Client is kicked, see auditKick.
+		disconnectReason        int32 // disconnectReason is the DisconnectReason set once atomically, see setDisconnectReason.
+
+		id          string    // id is assigned at accept and never changes, see Client.ID.
+		connectedAt time.Time // connectedAt is the time the Client is accepted.
+		opts        *ClientOptions
+		ctx         context.Context // ctx is the Client-level context, see Client.Context.
+		transport   Transport
+		mu          sync.Mutex          // mu guards state and tags.
+		state       ClientState         // state is guarded by mu.
+		tags        map[string]struct{} // tags is guarded by mu, see Client.AddTag.
+		tagsIndexed bool                // tagsIndexed is guarded by mu, true while the Client is registered, see BroadcastToTag.
+		trace       atomic.Value        // trace holds the *traceBuffer while tracing, see Client.StartTrace.
+		rttStats    rttStats            // rttStats is only accessed by writeLoop until it exits, see emitSessionSummary.
+		cancelCtx   context.CancelFunc  // cancelCtx cancels the Client-level context that creates inside StartClient and result in Client.Close() being called.
+		readCh      chan []byte
+		writeCh     chan []byte // writeCh is the buffered channel of messages waiting to write to the transport.
+		// writeLoopDoneCh is closed when writeLoop exits, after which it is safe to write to the transport from elsewhere.
+		writeLoopDoneCh chan struct{}
 	}
 )
 
 // StartClient creates a new Client with ClientStateConnected as the initial state,
-//
-func StartClient(ctx context.Context, transport Transport) error {
+// and blocks until the Client is closed, either by an error from the transport or by ctx being done.
+func StartClient(ctx context.Context, transport Transport, opts ...ClientOption) error {
+	// Apply opts to customize Client first, as the buffer sizes and the supported client versions are configurable.
+	o := defaultClientOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	countClientVersion(o.ClientVersion)
+	if e := checkClientVersion(o.ClientVersion, o.MinClientVersion, o.MaxClientVersion); e != nil {
+		// Tell the peer to update, a client seeing a non-retryable Error stops reconnecting.
+		atomic.AddInt64(&numUpdateRequired, 1)
+		writeError(transport, e)
+		return e
+	}
+
+	// Redirect the peer to a closer endpoint before serving it, it is not an error.
+	if m := regionRedirect(o); m != nil {
+		atomic.AddInt64(&numRegionRedirects, 1)
+		if data, err := m.Marshal(); err == nil {
+			_ = transport.Write(data)
+		}
+		return nil
+	}
+
+	// Check the capacity after the redirect, so a full node still sends the peers of other regions to their endpoints.
 	if ExceedMaxClients() {
+		// Tell the peer why before the connection is closed, so it can retry later instead of seeing a silent disconnect.
+		writeError(transport, ErrExceedMaxClients)
 		return ErrExceedMaxClients
 	}
+
+	id, err := newClientID()
+	if err != nil {
+		return fmt.Errorf("ppcserver: newClientID() error: %w", err)
+	}
 	incrNumClients()
 	defer decrNumClients()
 
+	// serverCtx is done only when the server is shutting down, unlike the Client-level ctx below.
+	serverCtx := ctx
+
 	// The ctx.Done channel returns from context.WithCancel() is closed when the cancelCtx() function is called
 	// or when the parent context's Done channel is closed, whichever happens first.
 	ctx, cancelCtx := context.WithCancel(ctx)
 	defer cancelCtx() // Call cancelCtx when StartClient exits to ensure the current Client's resources are fully released.
 
+	// Carry the RequestMetadata in the Client-level context for the handlers that only take a context.
+	if o.RequestMetadata != nil {
+		ctx = context.WithValue(ctx, requestMetadataKey{}, o.RequestMetadata)
+	}
+
 	c := &Client{
-		transport: transport,
-		state:     ClientStateConnected,
-		cancelCtx: cancelCtx,
-		readCh:    make(chan []byte),      // TODO, what is the buffer size?
-		writeCh:   make(chan []byte, 256), // TODO, buffer size is configurable
+		id:   id,
+		opts: o,
+		ctx:  ctx,
+
+		connectedAt: time.Now(),
+		transport:   transport,
+		state:       ClientStateConnected,
+		cancelCtx:   cancelCtx,
+		readCh:      make(chan []byte, o.ReadBufferSize),
+		writeCh:     make(chan []byte, o.WriteBufferSize),
+
+		writeLoopDoneCh: make(chan struct{}),
 	}
 
-	// if !allowToConnect() {
-	// 	return
-	// }
-	// if err := handshake(); err != nil {
-	// 	return
-	// }
+	registerClient(c)
+	defer unregisterClient(c)
+	countRegion(o.Region, 1)
+	defer countRegion(o.Region, -1)
+	if o.Region != "" {
+		c.AddTag(RegionTagPrefix + o.Region)
+	}
+	if c.opts.OnConnect != nil {
+		c.opts.OnConnect(c)
+	}
+	stopFeatureFlags := c.watchFeatureFlags()
+	defer stopFeatureFlags()
+
+	// Kick the Client if the application does not authorize it in time, see Client.Authorize.
+	if c.opts.AuthTimeout > 0 {
+		timer := time.AfterFunc(c.opts.AuthTimeout, c.checkAuthTimeout)
+		defer timer.Stop()
+	}
 
 	// The ctx.Done channel returns from errgroup.WithContext() will be closed
 	// when the first time either writeLoop or readLoop passed to g.Go() returns a non-nil error,
@@ -81,29 +175,44 @@ func StartClient(ctx context.Context, transport Transport) error {
 	)
 	g.Go(
 		func() error {
-			return c.readLoop()
+			return c.readLoop(ctx)
 		},
 	)
+	g.Go(c.handleLoop)
 
 	// Actively close the connection when ctx.Done channel is closed to force readLoop exits.
 	<-ctx.Done()
+	if serverCtx.Err() != nil {
+		c.setDisconnectReason(DisconnectReasonServerShutdown)
+		// Wait for writeLoop to flush and exit, as the transport supports only one concurrent writer.
+		<-c.writeLoopDoneCh
+		c.notifyShutdown()
+	}
 	_ = c.Close()
 
 	// Block until both readLoop and writeLoop exit to achieve a graceful shutdown of the Client.
 	// The g.Wait() will return the first error that causes the blocking exits.
-	return g.Wait()
+	err = g.Wait()
+	c.reportDisconnect()
+	c.emitSessionSummary()
+	return err
 }
 
 // Close first mutates Client to the ClientStateClosed state,
 // then closes the underlying transport connection with the peer.
 // Close does nothing if the Client's state is already ClientStateClosed.
+// Closing a Client with no DisconnectReason yet records DisconnectReasonKicked and audits the kick.
 func (c *Client) Close() (err error) {
+	if c.setDisconnectReason(DisconnectReasonKicked) {
+		c.auditKick("closed by the application")
+	}
+
 	defer func() {
 		if err != nil {
-			log.Println("ppcserver: Client.Close() error:", err)
+			log.Printf("ppcserver: Client(%s).Close() error: %v", c.id, err)
 			return
 		}
-		log.Println("ppcserver: Client.Close() complete")
+		log.Printf("ppcserver: Client(%s).Close() complete", c.id)
 	}()
 
 	// Change to the closed state should be guarded by mu. Skip if already in the closed state.
@@ -112,8 +221,10 @@ func (c *Client) Close() (err error) {
 		c.mu.Unlock()
 		return nil
 	}
+	from := c.state
 	c.state = ClientStateClosed
 	c.mu.Unlock()
+	c.notifyStateChange(from, ClientStateClosed)
 
 	// TODO, should send close message
 
@@ -124,40 +235,258 @@ func (c *Client) Close() (err error) {
 	return c.transport.Close()
 }
 
-// readLoop keep reading from the transport until transport.Read() errored.
-// The only reason readLoop exits is an error returns from transport.Read().
+// notifyShutdown writes ClientOptions.ShutdownMessage to the peer, if any, before the Client is closed.
+// The write is best-effort, since the connection is about to be closed anyway.
+func (c *Client) notifyShutdown() {
+	if len(c.opts.ShutdownMessage) == 0 {
+		return
+	}
+	if err := c.transport.Write(c.opts.ShutdownMessage); err != nil {
+		log.Printf("ppcserver: Client(%s).notifyShutdown() error: %v", c.id, err)
+	}
+}
+
+// readLoop keep reading from the transport until transport.Read() errored,
+// or the Client exceeds ClientOptions.BandwidthQuota under BandwidthPolicyDisconnect.
 // readLoop must execute by a single goroutine to ensure that there is at most one concurrent reader on a connection.
-func (c *Client) readLoop() error {
+func (c *Client) readLoop(ctx context.Context) error {
 	// The readLoop method is the only sender on readCh,
 	// so we Close the readCh here to ensure not sending on the closed readCh channel.
 	defer close(c.readCh)
 
+	quota := newBandwidthQuota(c.opts.BandwidthQuota, c.opts.BandwidthBurst)
 	for {
-		// TODO, here we actually use read timeout to break the loop
-
+		// The transport breaks the loop with ErrReadTimeout if the peer goes silent, see Options.ReadTimeout.
 		message, err := c.transport.Read()
 
 		// The connection must be closed once Read returns any error.
 		if err != nil {
-			return fmt.Errorf("ppcserver: Client.transport.Read() error: %w", err)
+			c.setDisconnectReason(readErrorReason(err))
+			return fmt.Errorf("ppcserver: Client(%s).transport.Read() error: %w", c.id, err)
+		}
+		c.addBytesIn(len(message))
+		c.traceMessage(TraceDirectionIn, message)
+		if quota != nil {
+			if err := c.applyBandwidthQuota(ctx, quota, len(message)); err != nil {
+				return err
+			}
 		}
 
-		log.Printf("ppcserver: Client.transport.Read() receive: %s", message)
-
-		// TODO, send to readCh, block when readCh is full
-		// case c.readCh <- message:
+		// Block when readCh is full, so a slow handler stops the reads and pushes back on the peer.
+		select {
+		case c.readCh <- message:
+		case <-ctx.Done():
+			return nil
+		}
 	}
 
 	// TODO, wait auth request from the peer.
 }
 
+// handleLoop invokes ClientOptions.OnMessage with every message read by readLoop in order, until readLoop exits.
+// The messages are discarded if OnMessage is not set.
+func (c *Client) handleLoop() error {
+	for message := range c.readCh {
+		if c.opts.OnMessage != nil {
+			c.opts.OnMessage(c, message)
+		}
+	}
+	return nil
+}
+
+// writeLoop writes the messages queued by Client.Write to the transport, and sends a ping to the peer
+// every ClientOptions.PingInterval if the transport is a Pinger, until ctx is done.
+// With ClientOptions.WriteCoalesceInterval set, the queued messages are coalesced and written together,
+// see WithWriteCoalescing for the details.
+// writeLoop must execute by a single goroutine to ensure that there is at most one concurrent writer on a connection.
 func (c *Client) writeLoop(ctx context.Context) error {
-	// ticker := time.NewTicker(pingPeriod)
-	// defer ticker.Stop()
+	defer close(c.writeLoopDoneCh)
+
+	if err := c.writeFeatureFlagsSnapshot(); err != nil {
+		return c.writeError(err)
+	}
+
+	// A nil channel blocks forever in select, which disables the corresponding case.
+	var pingCh, flushCh <-chan time.Time
+	pinger, ok := c.transport.(Pinger)
+	if ok && c.opts.PingInterval > 0 {
+		ticker := time.NewTicker(c.opts.PingInterval)
+		defer ticker.Stop()
+		pingCh = ticker.C
+	}
+	coalescing := c.opts.WriteCoalesceInterval > 0
+	if coalescing {
+		ticker := time.NewTicker(c.opts.WriteCoalesceInterval)
+		defer ticker.Stop()
+		flushCh = ticker.C
+	}
+
+	var (
+		pending     [][]byte
+		pendingSize int
+	)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := c.writeBatch(pending)
+		pending, pendingSize = pending[:0], 0
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Write the messages still queued as the last chance, after the pending ones if coalescing,
+			// which fails harmlessly if the transport is broken. The messages queued after this are dropped.
+			for drained := false; !drained; {
+				select {
+				case data := <-c.writeCh:
+					c.traceMessage(TraceDirectionOut, data)
+					if coalescing {
+						pending = append(pending, data)
+						continue
+					}
+					if err := c.transport.Write(data); err != nil {
+						return nil
+					}
+					c.addBytesOut(len(data))
+				default:
+					drained = true
+				}
+			}
+			_ = flush()
+			return nil
+		case data := <-c.writeCh:
+			c.traceMessage(TraceDirectionOut, data)
+			if !coalescing {
+				if err := c.transport.Write(data); err != nil {
+					return c.writeError(err)
+				}
+				c.addBytesOut(len(data))
+				continue
+			}
+			pending = append(pending, data)
+			pendingSize += len(data)
+			if c.opts.WriteCoalesceMaxBytes > 0 && pendingSize >= c.opts.WriteCoalesceMaxBytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-flushCh:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-pingCh:
+			// Sample the RTT measured by the previous ping for the session summary.
+			c.sampleRTT()
+			if err := pinger.Ping(); err != nil {
+				c.setDisconnectReason(DisconnectReasonWriteError)
+				return fmt.Errorf("ppcserver: Client(%s).transport.Ping() error: %w", c.id, err)
+			}
+		}
+	}
+}
 
+// writeBatch writes messages to the transport as a single message, with each message separated by a newline.
+// It uses BatchWriter if the transport implements it, to avoid joining the messages into a new buffer.
+func (c *Client) writeBatch(messages [][]byte) error {
+	var err error
+	if bw, ok := c.transport.(BatchWriter); ok {
+		err = bw.WriteBatch(messages)
+	} else {
+		err = c.transport.Write(bytes.Join(messages, batchSeparator))
+	}
+	if err != nil {
+		return c.writeError(err)
+	}
+	n := len(batchSeparator) * (len(messages) - 1)
+	for _, m := range messages {
+		n += len(m)
+	}
+	c.addBytesOut(n)
 	return nil
 }
 
+// writeError wraps err returned from writing to the transport,
+// and handles the Client as a slow consumer if err is caused by the write timeout.
+func (c *Client) writeError(err error) error {
+	if isTimeout(err) {
+		c.handleSlowConsumer(SlowConsumerReasonWriteTimeout)
+	}
+	c.setDisconnectReason(DisconnectReasonWriteError)
+	return fmt.Errorf("ppcserver: Client(%s).transport.Write() error: %w", c.id, err)
+}
+
+// ProtocolVersion returns the protocol version negotiated with the peer,
+// so the message handling can stay compatible with the older deployed clients when the protocol evolves.
+func (c *Client) ProtocolVersion() string {
+	return c.opts.ProtocolVersion
+}
+
+// PeerCertificates returns the certificates presented by the peer over TLS, the first one is the leaf,
+// or nil if the transport is not over TLS or the peer presents none.
+// For a transport without a single connection, such as SSE, they come from the RequestMetadata.
+// See WithClientCertAuth for requesting and verifying them.
+func (c *Client) PeerCertificates() []*x509.Certificate {
+	tlsConn, ok := c.transport.NetConn().(*tls.Conn)
+	if !ok {
+		if md := c.RequestMetadata(); md != nil {
+			return md.PeerCertificates
+		}
+		return nil
+	}
+	return tlsConn.ConnectionState().PeerCertificates
+}
+
+// Context returns the Client-level context, which carries the RequestMetadata, see RequestMetadataFrom.
+// It is done once the Client exits or the server is shutting down.
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// RTT returns the latest measured round-trip time with the peer,
+// or 0 if it is not measured yet or the transport does not support it.
+func (c *Client) RTT() time.Duration {
+	if pinger, ok := c.transport.(Pinger); ok {
+		return pinger.RTT()
+	}
+	return 0
+}
+
+// Authorize transitions the Client from ClientStateConnected to ClientStateAuthorized,
+// once the application has authenticated the peer, such as by a token from the upgrade request.
+// It returns false if the Client is not in ClientStateConnected, such as already closed.
+func (c *Client) Authorize() bool {
+	c.mu.Lock()
+	if c.state != ClientStateConnected {
+		c.mu.Unlock()
+		return false
+	}
+	c.state = ClientStateAuthorized
+	c.mu.Unlock()
+	c.notifyStateChange(ClientStateConnected, ClientStateAuthorized)
+	return true
+}
+
+// checkAuthTimeout kicks the Client with DisconnectReasonAuthTimeout if it is still not authorized.
+func (c *Client) checkAuthTimeout() {
+	if c.State() != ClientStateConnected {
+		return
+	}
+	if c.setDisconnectReason(DisconnectReasonAuthTimeout) {
+		c.auditKick("auth timeout")
+	}
+	c.cancelCtx()
+}
+
+// notifyStateChange invokes ClientOptions.OnStateChange, if any, it must be called without holding mu.
+func (c *Client) notifyStateChange(from, to ClientState) {
+	if c.opts.OnStateChange != nil {
+		c.opts.OnStateChange(c, from, to)
+	}
+}
+
 // State returns the current state of the Client.
 func (c *Client) State() ClientState {
 	c.mu.Lock()
@@ -165,14 +494,36 @@ func (c *Client) State() ClientState {
 	return c.state
 }
 
+// Write queues data to be written to the peer by writeLoop, it never blocks.
+// data is passed through ClientOptions.OutboundInterceptors first, which may replace or drop it.
+// If the write queue is full, the Client is handled as a slow consumer by ClientOptions.SlowConsumerPolicy,
+// and ErrWriteQueueFull is returned with data dropped.
+// If the Client is closed, ErrClientClosed is returned, so a stale reference can not fill the queue that nobody drains.
+// Write is safe for concurrent use.
 func (c *Client) Write(data []byte) error {
+	if c.State() == ClientStateClosed {
+		return ErrClientClosed
+	}
+
+	for _, intercept := range c.opts.OutboundInterceptors {
+		var err error
+		if data, err = intercept(c, data); err != nil {
+			return fmt.Errorf("ppcserver: OutboundInterceptor error: %w", err)
+		}
+		if data == nil {
+			return nil
+		}
+	}
+
 	select {
 	case c.writeCh <- data:
+		c.recordWriteQueueLen(len(c.writeCh))
+		return nil
 	default:
-		// TODO, do we need to close the writeCh when its buffer is full?
-		close(c.writeCh)
+		atomic.AddInt64(&c.droppedMessages, 1)
+		c.handleSlowConsumer(SlowConsumerReasonQueueFull)
+		return ErrWriteQueueFull
 	}
-	return nil
 }
 
 func (c *Client) heartbeat() {
@@ -0,0 +1,256 @@
+package connector
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/analytics"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"log"
+	"time"
+)
+
+// defaultWriteBufferSize is the default of ClientOptions.WriteBufferSize.
+const defaultWriteBufferSize = 256
+
+type (
+	// ClientOption is a function to apply various configurations to customize a Client.
+	ClientOption func(o *ClientOptions)
+
+	// ClientHook is invoked with a Client on its lifecycle events, see ClientOptions.OnConnect.
+	ClientHook func(c *Client)
+
+	// MessageHandler is invoked with every message read from a Client, see ClientOptions.OnMessage.
+	MessageHandler func(c *Client, message []byte)
+
+	// StateChangeHook is invoked when a Client transitions from one ClientState to another.
+	StateChangeHook func(c *Client, from, to ClientState)
+
+	// OutboundInterceptor is applied to data in Client.Write before it is queued, and returns the data to write instead,
+	// such as for logging, per-user filtering, localization or sampling.
+	// Returning a nil data drops the message silently, returning an error drops it and Client.Write returns the error.
+	OutboundInterceptor func(c *Client, data []byte) ([]byte, error)
+
+	// ClientOptions defines the configurable opts of a Client.
+	ClientOptions struct {
+		// ReadBufferSize is the number of read messages buffered for OnMessage,
+		// the Client stops reading from the peer while the buffer is full.
+		// Default is 0 (unbuffered) if not set via WithReadBuffer, or set to less than 0.
+		ReadBufferSize int
+
+		// WriteBufferSize is the number of messages the write queue holds before the Client is a slow consumer.
+		// Default is 256 if not set via WithWriteBuffer, or set to less than 1.
+		WriteBufferSize int
+
+		// AuthTimeout is how long the Client may stay in ClientStateConnected before it is kicked,
+		// the application ends the state by Client.Authorize.
+		// No timeout if not set via WithAuthTimeout.
+		AuthTimeout time.Duration
+
+		// OnStateChange is invoked on every ClientState transition of the Client, if not nil.
+		OnStateChange StateChangeHook
+
+		// ShutdownMessage is written to the peer right before the connection is closed due to the server shutting down.
+		// No message is written if not set via WithShutdownMessage.
+		ShutdownMessage []byte
+
+		// PingInterval is how often a ping is sent to the peer to measure the RTT, if the Transport is a Pinger.
+		// No ping is sent if PingInterval is not positive.
+		PingInterval time.Duration
+
+		// WriteCoalesceInterval is how often the coalesced messages are written, 0 disables the coalescing.
+		// See WithWriteCoalescing for the details.
+		WriteCoalesceInterval time.Duration
+
+		// WriteCoalesceMaxBytes is the size in bytes of the coalesced messages that triggers a write before
+		// WriteCoalesceInterval elapses, 0 means no limit.
+		WriteCoalesceMaxBytes int
+
+		// SlowConsumerPolicy decides what happens to the Client once it is detected as a slow consumer.
+		// Default is SlowConsumerPolicyKick.
+		SlowConsumerPolicy SlowConsumerPolicy
+
+		// OnSlowConsumer is invoked when the Client is first detected as a slow consumer, if not nil.
+		OnSlowConsumer SlowConsumerHook
+
+		// OnConnect is invoked when the Client is started, before any message is read, if not nil.
+		// It is where the application gets the Client, such as to attach tags via Client.AddTag.
+		OnConnect ClientHook
+
+		// OnDisconnect is invoked after the Client is closed, with its DisconnectReason, if not nil.
+		OnDisconnect DisconnectHook
+
+		// OnMessage is invoked with every message read from the Client in order, if not nil.
+		// It runs on a single goroutine per Client, and a slow OnMessage fills the read buffer, see ReadBufferSize.
+		// StartClient does not return until the running OnMessage returns.
+		OnMessage MessageHandler
+
+		// OutboundInterceptors are applied in order to the data of every Client.Write.
+		OutboundInterceptors []OutboundInterceptor
+
+		// BandwidthQuota is the bytes per second allowed to read from the Client, 0 means no quota.
+		// BandwidthBurst is the bytes allowed to read at once, raised to BandwidthQuota if less.
+		// BandwidthPolicy decides what happens once the Client exceeds the quota, default is BandwidthPolicyThrottle.
+		BandwidthQuota  int
+		BandwidthBurst  int
+		BandwidthPolicy BandwidthPolicy
+
+		// AuditSink receives the audit events of the Client, such as being kicked, if not nil.
+		AuditSink audit.Sink
+
+		// ProtocolVersion is the protocol version negotiated with the peer.
+		// Default is ProtocolVersion1 if not set via WithProtocolVersion.
+		ProtocolVersion string
+
+		// RequestMetadata is the metadata of the request that opens the connection, if any.
+		// Connectors set it via WithRequestMetadata, see Client.RequestMetadata and Client.Context.
+		RequestMetadata *RequestMetadata
+
+		// ClientVersion is the build version sent by the peer on connecting, see ClientVersionParam.
+		// Connectors set it via WithClientVersion.
+		ClientVersion string
+
+		// MinClientVersion and MaxClientVersion are the range of the supported ClientVersion, see WithClientVersionRange.
+		MinClientVersion string
+		MaxClientVersion string
+
+		// FeatureFlags are consulted by the handlers via Client.FeatureFlags, and pushed to the peer if not nil.
+		FeatureFlags featureflag.FeatureFlags
+
+		// SessionAnalytics receives a SessionSummaryEvent when the Client exits, if not nil.
+		SessionAnalytics analytics.Emitter
+
+		// Region is the region of the peer resolved at connect time, connectors set it via WithRegion.
+		Region string
+
+		// LocalRegion and RegionEndpoints redirect the Client to the endpoint of its Region, see WithRegionRedirect.
+		LocalRegion     string
+		RegionEndpoints map[string]string
+
+		// registry is the clientRegistry of the connector accepting the Client, nil if started by StartClient directly.
+		registry *clientRegistry
+	}
+)
+
+func defaultClientOptions() *ClientOptions {
+	return &ClientOptions{
+		WriteBufferSize: defaultWriteBufferSize,
+		ProtocolVersion: ProtocolVersion1,
+	}
+}
+
+// newClientOptions returns the ClientOptions derived from the connector Options, shared by every accepted connection.
+func newClientOptions(opts *Options) []ClientOption {
+	clientOpts := []ClientOption{
+		func(o *ClientOptions) {
+			o.PingInterval = opts.PingInterval
+			o.WriteCoalesceInterval = opts.WriteCoalesceInterval
+			o.WriteCoalesceMaxBytes = opts.WriteCoalesceMaxBytes
+			o.SlowConsumerPolicy = opts.SlowConsumerPolicy
+			o.OnSlowConsumer = opts.OnSlowConsumer
+			o.OnConnect = opts.OnConnect
+			o.OnDisconnect = opts.OnDisconnect
+			o.OnMessage = opts.OnMessage
+			o.OutboundInterceptors = opts.OutboundInterceptors
+			o.BandwidthQuota = opts.BandwidthQuota
+			o.BandwidthBurst = opts.BandwidthBurst
+			o.BandwidthPolicy = opts.BandwidthPolicy
+			o.AuditSink = opts.AuditSink
+			o.MinClientVersion = opts.MinClientVersion
+			o.MaxClientVersion = opts.MaxClientVersion
+			o.FeatureFlags = opts.FeatureFlags
+			o.SessionAnalytics = opts.SessionAnalytics
+			o.LocalRegion = opts.LocalRegion
+			o.RegionEndpoints = opts.RegionEndpoints
+		},
+	}
+	if opts.ShutdownNotice != nil {
+		// Marshal once here instead of per Client, since the notice is the same for every Client.
+		if data, err := opts.ShutdownNotice.Marshal(); err != nil {
+			log.Println("ppcserver: ShutdownNotice.Marshal() error:", err)
+		} else {
+			clientOpts = append(clientOpts, WithShutdownMessage(data))
+		}
+	}
+	// The ClientOptions set via WithClientOptions go last, so they override the ones derived above.
+	return append(clientOpts, opts.ClientOptions...)
+}
+
+// withClientRegistry is a ClientOption for a connector to register the Clients it accepts to r.
+func withClientRegistry(r *clientRegistry) ClientOption {
+	return func(o *ClientOptions) {
+		o.registry = r
+	}
+}
+
+// WithShutdownMessage is a ClientOption to set the message written to the peer when the server is shutting down.
+func WithShutdownMessage(data []byte) ClientOption {
+	return func(o *ClientOptions) {
+		o.ShutdownMessage = data
+	}
+}
+
+// WithProtocolVersion is a ClientOption to set the protocol version negotiated with the peer.
+func WithProtocolVersion(v string) ClientOption {
+	return func(o *ClientOptions) {
+		o.ProtocolVersion = v
+	}
+}
+
+// WithRequestMetadata is a ClientOption to set the metadata of the request that opens the connection.
+func WithRequestMetadata(md *RequestMetadata) ClientOption {
+	return func(o *ClientOptions) {
+		o.RequestMetadata = md
+	}
+}
+
+// WithClientVersion is a ClientOption to set the build version sent by the peer on connecting.
+func WithClientVersion(v string) ClientOption {
+	return func(o *ClientOptions) {
+		o.ClientVersion = v
+	}
+}
+
+// WithRegion is a ClientOption to set the region of the peer resolved at connect time.
+func WithRegion(region string) ClientOption {
+	return func(o *ClientOptions) {
+		o.Region = region
+	}
+}
+
+// WithReadBuffer is a ClientOption to set the number of read messages buffered for ClientOptions.OnMessage,
+// a larger buffer absorbs the bursts of a peer before the Client stops reading from it.
+// n less than 0 is treated as 0, which is unbuffered.
+func WithReadBuffer(n int) ClientOption {
+	return func(o *ClientOptions) {
+		if n < 0 {
+			n = 0
+		}
+		o.ReadBufferSize = n
+	}
+}
+
+// WithWriteBuffer is a ClientOption to set the number of messages the write queue holds,
+// a larger queue tolerates longer bursts before the Client is handled as a slow consumer but uses more memory.
+// n less than 1 applies the default, since a queue holding nothing would handle every Client as a slow consumer.
+func WithWriteBuffer(n int) ClientOption {
+	return func(o *ClientOptions) {
+		if n < 1 {
+			n = defaultWriteBufferSize
+		}
+		o.WriteBufferSize = n
+	}
+}
+
+// WithAuthTimeout is a ClientOption to kick the Client with DisconnectReasonAuthTimeout
+// if it is not authorized by Client.Authorize within d.
+func WithAuthTimeout(d time.Duration) ClientOption {
+	return func(o *ClientOptions) {
+		o.AuthTimeout = d
+	}
+}
+
+// WithOnStateChange is a ClientOption to set the hook invoked on every ClientState transition of the Client.
+func WithOnStateChange(hook StateChangeHook) ClientOption {
+	return func(o *ClientOptions) {
+		o.OnStateChange = hook
+	}
+}
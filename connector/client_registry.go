@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// crockfordBase32 is the alphabet of the Client IDs, which excludes I, L, O and U to avoid confusion when read aloud.
+	crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	// numClientShards is the number of shards of the Client registry, so the connects and disconnects of
+	// many Clients at once do not contend on a single lock.
+	numClientShards = 64
+)
+
+// clients are the Clients started and not yet exited on this node, across all the connectors.
+var clients = newClientRegistry()
+
+type (
+	// clientRegistry holds the Clients started and not yet exited by ID,
+	// spread over the shards by the hash of the ID.
+	clientRegistry struct {
+		shards [numClientShards]clientShard
+	}
+
+	// clientShard is a shard of a clientRegistry.
+	clientShard struct {
+		mu      sync.RWMutex
+		clients map[string]*Client // clients is guarded by mu.
+	}
+)
+
+func newClientRegistry() *clientRegistry {
+	r := &clientRegistry{}
+	for i := range r.shards {
+		r.shards[i].clients = make(map[string]*Client)
+	}
+	return r
+}
+
+// shardOf returns the shard of the Client with id, by the FNV-1a hash of id.
+func (r *clientRegistry) shardOf(id string) *clientShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &r.shards[h%numClientShards]
+}
+
+func (r *clientRegistry) add(c *Client) {
+	shard := r.shardOf(c.id)
+	shard.mu.Lock()
+	shard.clients[c.id] = c
+	shard.mu.Unlock()
+}
+
+func (r *clientRegistry) remove(c *Client) {
+	shard := r.shardOf(c.id)
+	shard.mu.Lock()
+	delete(shard.clients, c.id)
+	shard.mu.Unlock()
+}
+
+func (r *clientRegistry) get(id string) (*Client, bool) {
+	shard := r.shardOf(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	c, ok := shard.clients[id]
+	return c, ok
+}
+
+// snapshot returns the Clients in r sorted by ID.
+func (r *clientRegistry) snapshot() []*Client {
+	var snapshot []*Client
+	for i := range r.shards {
+		shard := &r.shards[i]
+		shard.mu.RLock()
+		for _, c := range shard.clients {
+			snapshot = append(snapshot, c)
+		}
+		shard.mu.RUnlock()
+	}
+
+	sort.Slice(
+		snapshot, func(i, j int) bool {
+			return snapshot[i].id < snapshot[j].id
+		},
+	)
+	return snapshot
+}
+
+// ID returns the unique ID assigned to the Client when it is accepted.
+// The IDs are 26-character ULIDs, which sort by the millisecond the Clients are accepted,
+// while the IDs assigned within the same millisecond are in random order.
+func (c *Client) ID() string {
+	return c.id
+}
+
+// ClientByID returns the Client with id on this node across all the connectors,
+// or false if no such Client is started and not yet exited. See also WebsocketConnector.Client.
+func ClientByID(id string) (*Client, bool) {
+	return clients.get(id)
+}
+
+// Clients returns a snapshot of the Clients on this node across all the connectors sorted by ID,
+// which is the order they are accepted to the millisecond, see Client.ID.
+// The Clients accepted or exited after Clients returns are not reflected, check Client.State before acting on one.
+// See also WebsocketConnector.Clients.
+func Clients() []*Client {
+	return clients.snapshot()
+}
+
+// registerClient adds c to the registry of this node and of its connector, if any,
+// and its tags to the tag index of BroadcastToTag.
+func registerClient(c *Client) {
+	clients.add(c)
+	if c.opts.registry != nil {
+		c.opts.registry.add(c)
+	}
+	c.indexTags()
+}
+
+// unregisterClient removes c from the registries and the tag index.
+func unregisterClient(c *Client) {
+	c.unindexTags()
+	if c.opts.registry != nil {
+		c.opts.registry.remove(c)
+	}
+	clients.remove(c)
+}
+
+// newClientID returns a ULID, which is a 48-bit millisecond timestamp followed by 80 random bits
+// encoded in 26 characters of Crockford's base32.
+func newClientID() (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	// 26 characters carry 130 bits, the 128 bits of b are encoded with 2 leading zero bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:]), nil
+}
@@ -0,0 +1,238 @@
+package connector_test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// clientIDTime decodes the millisecond timestamp in the first 10 characters of the ULID id.
+func clientIDTime(t *testing.T, id string) time.Time {
+	t.Helper()
+	var ms int64
+	for _, r := range id[:10] {
+		i := strings.IndexRune(crockfordBase32, r)
+		if i < 0 {
+			t.Fatalf("ID %q has a character %q out of Crockford's base32", id, r)
+		}
+		ms = ms<<5 | int64(i)
+	}
+	return time.UnixMilli(ms)
+}
+
+func TestNewClientID(t *testing.T) {
+	const n = 1000
+	before := time.Now().Truncate(time.Millisecond)
+	seen := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		id, err := connector.NewClientID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 {
+			t.Fatalf("len(%q) = %d, want 26", id, len(id))
+		}
+		for _, r := range id {
+			if !strings.ContainsRune(crockfordBase32, r) {
+				t.Fatalf("ID %q has a character %q out of Crockford's base32", id, r)
+			}
+		}
+		if id[0] > '7' {
+			t.Fatalf("ID %q overflows 128 bits", id)
+		}
+		if seen[id] {
+			t.Fatalf("ID %q is generated twice", id)
+		}
+		seen[id] = true
+
+		if ts := clientIDTime(t, id); ts.Before(before) || ts.After(time.Now()) {
+			t.Fatalf("timestamp of %q = %s, want between %s and now", id, ts, before)
+		}
+	}
+}
+
+func TestNewClientIDSortable(t *testing.T) {
+	prev, err := connector.NewClientID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		id, err := connector.NewClientID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= prev {
+			t.Fatalf("ID %q generated later sorts before %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+// startAndDial starts a WebsocketServer, dials it, and returns the Client accepted for the connection.
+func startAndDial(t *testing.T) (*ppctest.WebsocketServer, *websocket.Conn, *connector.Client) {
+	t.Helper()
+	clientCh := make(chan *connector.Client, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnConnect(
+			func(c *connector.Client) {
+				clientCh <- c
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		_ = s.Close()
+		t.Fatal(err)
+	}
+	select {
+	case c := <-clientCh:
+		return s, conn, c
+	case <-time.After(time.Second):
+		_ = s.Close()
+		t.Fatal("the Client is not started")
+		return nil, nil, nil
+	}
+}
+
+func TestConnectorClients(t *testing.T) {
+	s1, conn1, c1 := startAndDial(t)
+	defer s1.Close()
+	s2, conn2, c2 := startAndDial(t)
+	defer s2.Close()
+	defer conn2.Close()
+
+	// Each connector only sees its own Clients, while ClientByID sees all the Clients on this node.
+	if got := s1.Connector.Clients(); len(got) != 1 || got[0] != c1 {
+		t.Fatalf("s1.Clients() = %v, want only its Client", got)
+	}
+	if got, ok := s1.Connector.Client(c1.ID()); !ok || got != c1 {
+		t.Fatalf("s1.Client(c1) = %v, %t, want c1", got, ok)
+	}
+	if _, ok := s1.Connector.Client(c2.ID()); ok {
+		t.Fatal("s1.Client(c2) is found, want only the Clients of s1")
+	}
+	for _, c := range []*connector.Client{c1, c2} {
+		if got, ok := connector.ClientByID(c.ID()); !ok || got != c {
+			t.Fatalf("ClientByID(%s) = %v, %t, want the Client", c.ID(), got, ok)
+		}
+	}
+
+	_ = conn1.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(s1.Connector.Clients()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the Client is still in s1.Clients() after the peer closes")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := connector.ClientByID(c1.ID()); ok {
+		t.Fatal("ClientByID() finds the exited Client")
+	}
+	if got := s2.Connector.Clients(); len(got) != 1 || got[0] != c2 {
+		t.Fatalf("s2.Clients() = %v, want only its Client", got)
+	}
+}
+
+// benchmarkNumClients is the number of Clients registered before the benchmarks of the registry are measured.
+const benchmarkNumClients = 100000
+
+// singleLockRegistry is the registry behind a single RWMutex that the sharded registry replaces,
+// for comparing the benchmarks against.
+type singleLockRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]*connector.Client
+}
+
+func (r *singleLockRegistry) register(id string) (unregister func()) {
+	r.mu.Lock()
+	r.clients[id] = &connector.Client{}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.clients, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *singleLockRegistry) lookup(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.clients[id]
+	return ok
+}
+
+// benchmarkClientIDs returns n distinct IDs with the given prefix.
+func benchmarkClientIDs(prefix string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = prefix + strconv.Itoa(i)
+	}
+	return ids
+}
+
+// benchmarkRegister measures the connects and disconnects in parallel, with benchmarkNumClients already registered.
+func benchmarkRegister(b *testing.B, prefix string, register func(id string) (unregister func())) {
+	for _, id := range benchmarkClientIDs(prefix+"-idle-", benchmarkNumClients) {
+		defer register(id)()
+	}
+	var seq int64
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			id := prefix + "-" + strconv.FormatInt(atomic.AddInt64(&seq, 1), 10) + "-"
+			for i := 0; pb.Next(); i++ {
+				register(id + strconv.Itoa(i))()
+			}
+		},
+	)
+}
+
+// benchmarkLookup measures the lookups in parallel among benchmarkNumClients registered.
+func benchmarkLookup(b *testing.B, prefix string, register func(id string) (unregister func()), lookup func(id string) bool) {
+	ids := benchmarkClientIDs(prefix+"-", benchmarkNumClients)
+	for _, id := range ids {
+		defer register(id)()
+	}
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if !lookup(ids[i%len(ids)]) {
+					b.Fatal("the registered Client is not found")
+				}
+			}
+		},
+	)
+}
+
+func BenchmarkRegisterClient(b *testing.B) {
+	r := connector.NewClientRegistry()
+	benchmarkRegister(b, "BenchmarkRegisterClient", r.Register)
+}
+
+func BenchmarkRegisterClientSingleLock(b *testing.B) {
+	r := &singleLockRegistry{clients: make(map[string]*connector.Client)}
+	benchmarkRegister(b, "BenchmarkRegisterClientSingleLock", r.register)
+}
+
+func BenchmarkClientByID(b *testing.B) {
+	r := connector.NewClientRegistry()
+	benchmarkLookup(b, "BenchmarkClientByID", r.Register, r.Lookup)
+}
+
+func BenchmarkClientByIDSingleLock(b *testing.B) {
+	r := &singleLockRegistry{clients: make(map[string]*connector.Client)}
+	benchmarkLookup(b, "BenchmarkClientByIDSingleLock", r.register, r.lookup)
+}
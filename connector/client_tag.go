@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	// clientsByTag indexes the registered Clients by their tags for BroadcastToTag, guarded by clientsByTagMu.
+	// A Client's mu is always acquired before clientsByTagMu, so its tags and the index change together.
+	clientsByTagMu sync.RWMutex
+	clientsByTag   = make(map[string]map[*Client]struct{})
+)
+
+// AddTag attaches tag to the Client, such as "platform:ios" or "league:gold", for BroadcastToTag to target.
+// AddTag is safe for concurrent use.
+func (c *Client) AddTag(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tags == nil {
+		c.tags = make(map[string]struct{})
+	}
+	c.tags[tag] = struct{}{}
+	if c.tagsIndexed {
+		indexTag(tag, c)
+	}
+}
+
+// RemoveTag detaches tag from the Client, it does nothing if the Client does not have tag.
+// RemoveTag is safe for concurrent use.
+func (c *Client) RemoveTag(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tags, tag)
+	if c.tagsIndexed {
+		unindexTag(tag, c)
+	}
+}
+
+// HasTag reports whether tag is attached to the Client.
+func (c *Client) HasTag(tag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.tags[tag]
+	return ok
+}
+
+// Tags returns the sorted tags attached to the Client.
+func (c *Client) Tags() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tags := make([]string, 0, len(c.tags))
+	for tag := range c.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// BroadcastToTag writes data to every Client on this node with tag attached, and returns the number of Clients written.
+// The write to each Client is non-blocking as Client.Write, a Client with its write queue full is skipped.
+func BroadcastToTag(tag string, data []byte) int {
+	// Collect the targets first to not hold clientsByTagMu while writing, as a write may kick a slow consumer.
+	clientsByTagMu.RLock()
+	targets := make([]*Client, 0, len(clientsByTag[tag]))
+	for c := range clientsByTag[tag] {
+		targets = append(targets, c)
+	}
+	clientsByTagMu.RUnlock()
+
+	n := 0
+	for _, c := range targets {
+		if err := c.Write(data); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// indexTags adds the tags of c to clientsByTag, and keeps the index updated by AddTag and RemoveTag afterward.
+func (c *Client) indexTags() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tagsIndexed = true
+	for tag := range c.tags {
+		indexTag(tag, c)
+	}
+}
+
+// unindexTags removes the tags of c from clientsByTag, and stops AddTag and RemoveTag updating the index.
+func (c *Client) unindexTags() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tagsIndexed = false
+	for tag := range c.tags {
+		unindexTag(tag, c)
+	}
+}
+
+// indexTag adds c to the Clients with tag, the caller must hold c.mu.
+func indexTag(tag string, c *Client) {
+	clientsByTagMu.Lock()
+	defer clientsByTagMu.Unlock()
+	set, ok := clientsByTag[tag]
+	if !ok {
+		set = make(map[*Client]struct{})
+		clientsByTag[tag] = set
+	}
+	set[c] = struct{}{}
+}
+
+// unindexTag removes c from the Clients with tag, the caller must hold c.mu.
+func unindexTag(tag string, c *Client) {
+	clientsByTagMu.Lock()
+	defer clientsByTagMu.Unlock()
+	delete(clientsByTag[tag], c)
+	if len(clientsByTag[tag]) == 0 {
+		delete(clientsByTag, tag)
+	}
+}
@@ -0,0 +1,63 @@
+package connector_test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+func TestBroadcastToTag(t *testing.T) {
+	clientCh := make(chan *connector.Client, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnConnect(
+			func(c *connector.Client) {
+				c.AddTag("league:gold")
+				clientCh <- c
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := <-clientCh
+
+	if n := connector.BroadcastToTag("league:gold", []byte("hello")); n != 1 {
+		t.Fatalf("BroadcastToTag() = %d, want 1", n)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadMessage() = %q, %v, want hello", data, err)
+	}
+	if n := connector.BroadcastToTag("league:silver", []byte("hello")); n != 0 {
+		t.Fatalf("BroadcastToTag() to another tag = %d, want 0", n)
+	}
+
+	c.RemoveTag("league:gold")
+	if n := connector.BroadcastToTag("league:gold", []byte("hello")); n != 0 {
+		t.Fatalf("BroadcastToTag() after RemoveTag() = %d, want 0", n)
+	}
+
+	c.AddTag("league:gold")
+	_ = conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := connector.ClientByID(c.ID()); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the Client is still registered after the peer closes")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := connector.BroadcastToTag("league:gold", []byte("hello")); n != 0 {
+		t.Fatalf("BroadcastToTag() after the Client exits = %d, want 0", n)
+	}
+}
@@ -0,0 +1,286 @@
+package connector_test
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnMessage(t *testing.T) {
+	const n = 16
+	messageCh := make(chan string, n)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnMessage(
+			func(_ *connector.Client, message []byte) {
+				messageCh <- string(message)
+			},
+		),
+		connector.WithClientOptions(connector.WithReadBuffer(4)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < n; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case message := <-messageCh:
+			if message != strconv.Itoa(i) {
+				t.Fatalf("message #%d = %q, want %d", i, message, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message #%d is not handled", i)
+		}
+	}
+}
+
+func TestWithReadBufferNegative(t *testing.T) {
+	messageCh := make(chan string, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnMessage(
+			func(_ *connector.Client, message []byte) {
+				messageCh <- string(message)
+			},
+		),
+		connector.WithClientOptions(connector.WithReadBuffer(-1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messageCh:
+		if message != "hello" {
+			t.Fatalf("message = %q, want %q", message, "hello")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the message is not handled")
+	}
+}
+
+func TestWithWriteBufferZero(t *testing.T) {
+	const n = 8
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnMessage(
+			func(c *connector.Client, message []byte) {
+				for i := 0; i < n; i++ {
+					if err := c.Write([]byte(strconv.Itoa(i))); err != nil {
+						t.Errorf("Write() error = %v", err)
+					}
+				}
+			},
+		),
+		connector.WithClientOptions(connector.WithWriteBuffer(0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < n; i++ {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() #%d error = %v, want the message", i, err)
+		}
+		if string(data) != strconv.Itoa(i) {
+			t.Fatalf("message #%d = %q, want %d", i, data, i)
+		}
+	}
+}
+
+// TestClientConcurrentUse exercises the concurrency contract documented on Client, run it with -race to verify it:
+// the exported methods are called concurrently on real Clients while their peers disconnect or they are closed.
+func TestClientConcurrentUse(t *testing.T) {
+	const (
+		numClients = 8
+		numCalls   = 200
+		tag        = "TestClientConcurrentUse"
+	)
+	clientCh := make(chan *connector.Client, numClients)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithOnMessage(
+			func(c *connector.Client, message []byte) {
+				if string(message) == "hello" {
+					clientCh <- c
+				}
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conns := make([]*websocket.Conn, numClients)
+	for i := range conns {
+		conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		// Keep reading so that the writes of the Client do not back up.
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	clients := make([]*connector.Client, numClients)
+	for i := range clients {
+		select {
+		case clients[i] = <-clientCh:
+		case <-time.After(time.Second):
+			t.Fatalf("Client #%d is not started", i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numCalls; i++ {
+				f(i)
+			}
+		}()
+	}
+	for i, c := range clients {
+		c, conn, closedByServer := c, conns[i], i%2 == 0
+		run(func(int) { _ = c.Write([]byte("data")) })
+		run(
+			func(i int) {
+				if i%2 == 0 {
+					c.AddTag(tag)
+				} else {
+					c.RemoveTag(tag)
+				}
+				_ = c.HasTag(tag)
+				_ = c.Tags()
+			},
+		)
+		run(func(int) { connector.BroadcastToTag(tag, []byte("broadcast")) })
+		run(
+			func(i int) {
+				switch i % 3 {
+				case 0:
+					c.StartTrace(8)
+				case 1:
+					_ = c.Trace()
+				default:
+					_ = c.StopTrace()
+				}
+			},
+		)
+		run(
+			func(i int) {
+				_ = c.State()
+				_ = c.ID()
+				_ = c.RTT()
+				_ = c.BytesOut()
+			},
+		)
+		// Half of the Clients are closed by the server, and the other half by their peers, while in use.
+		run(
+			func(i int) {
+				if i != numCalls/2 {
+					return
+				}
+				if closedByServer {
+					_ = c.Close()
+				} else {
+					_ = conn.Close()
+				}
+			},
+		)
+	}
+	wg.Wait()
+}
+
+func TestWriteQueueDrainedOnShutdown(t *testing.T) {
+	for _, coalesce := range []time.Duration{0, time.Hour} {
+		const n = 16
+		// The peer does not receive until the server is shutting down, so the messages back up in the write queue.
+		transport := ppctest.NewTransport(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		queuedCh := make(chan struct{})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- connector.StartClient(
+				ctx, transport,
+				func(o *connector.ClientOptions) {
+					o.WriteCoalesceInterval = coalesce
+					o.OnConnect = func(c *connector.Client) {
+						for i := 0; i < n; i++ {
+							_ = c.Write([]byte(strconv.Itoa(i)))
+						}
+						close(queuedCh)
+					}
+				},
+			)
+		}()
+		<-queuedCh
+		cancel()
+
+		var received []string
+		receiveCtx, cancelReceive := context.WithTimeout(context.Background(), time.Second)
+		for len(received) < n {
+			data, err := transport.Receive(receiveCtx)
+			if err != nil {
+				break
+			}
+			received = append(received, strings.Split(string(data), "\n")...)
+		}
+		cancelReceive()
+		<-errCh
+
+		if len(received) != n {
+			t.Fatalf("coalesce %s: received %d messages %v, want %d", coalesce, len(received), received, n)
+		}
+		for i, message := range received {
+			if message != strconv.Itoa(i) {
+				t.Fatalf("coalesce %s: message #%d = %q, want %d", coalesce, i, message, i)
+			}
+		}
+	}
+}
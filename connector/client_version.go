@@ -0,0 +1,160 @@
+package connector
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// ClientVersionParam is the query parameter for a client to send its build version on connecting,
+	// such as "ws://localhost:8080/ws?client_version=1.4.2", as browsers can not set headers on a WebSocket.
+	ClientVersionParam = "client_version"
+	// ClientVersionHeader is the header for a non-browser client to send its build version, see ClientVersionParam.
+	ClientVersionHeader = "X-Client-Version"
+
+	// maxTrackedClientVersions bounds the distinct versions counted by NumClientsByVersion,
+	// as the versions are sent by the peers and may be arbitrary.
+	maxTrackedClientVersions = 256
+	// otherClientVersion counts the versions beyond maxTrackedClientVersions.
+	otherClientVersion = "other"
+	// unknownClientVersion counts the clients not sending their versions.
+	unknownClientVersion = "unknown"
+)
+
+var (
+	numUpdateRequired int64
+
+	// clientVersions counts the started Clients by version, guarded by clientVersionsMu.
+	clientVersionsMu sync.Mutex
+	clientVersions   = make(map[string]int64)
+)
+
+// ClientVersion returns the build version sent by the peer on connecting, or an empty string if none.
+func (c *Client) ClientVersion() string {
+	return c.opts.ClientVersion
+}
+
+// NumUpdateRequired returns the number of Clients rejected by the supported client version range since the start.
+func NumUpdateRequired() int64 {
+	return atomic.LoadInt64(&numUpdateRequired)
+}
+
+// NumClientsByVersion returns the number of Clients started since the start by their ClientVersion,
+// including the ones rejected for an update.
+// The clients not sending their versions are counted as "unknown",
+// and the versions beyond the first 256 distinct ones are counted together as "other".
+func NumClientsByVersion() map[string]int64 {
+	clientVersionsMu.Lock()
+	defer clientVersionsMu.Unlock()
+	snapshot := make(map[string]int64, len(clientVersions))
+	for v, n := range clientVersions {
+		snapshot[v] = n
+	}
+	return snapshot
+}
+
+// countClientVersion counts a started Client of version for NumClientsByVersion.
+func countClientVersion(version string) {
+	if version == "" {
+		version = unknownClientVersion
+	}
+	clientVersionsMu.Lock()
+	defer clientVersionsMu.Unlock()
+	if _, ok := clientVersions[version]; !ok && len(clientVersions) >= maxTrackedClientVersions {
+		version = otherClientVersion
+	}
+	clientVersions[version]++
+}
+
+// clientVersion returns the build version sent by the peer of r, see ClientVersionParam and ClientVersionHeader.
+func clientVersion(r *http.Request) string {
+	if v := r.URL.Query().Get(ClientVersionParam); v != "" {
+		return v
+	}
+	return r.Header.Get(ClientVersionHeader)
+}
+
+// checkClientVersion returns an Error of ErrorCodeUpdateRequired if version is out of [minVersion, maxVersion],
+// an empty bound is not checked. A version that can not be parsed is treated as out of range,
+// so is an empty version when any bound is set, as it comes from a build predating the versioning.
+func checkClientVersion(version, minVersion, maxVersion string) *Error {
+	if minVersion == "" && maxVersion == "" {
+		return nil
+	}
+
+	supported := true
+	if v, ok := parseClientVersion(version); !ok {
+		supported = false
+	} else {
+		if minV, ok := parseClientVersion(minVersion); ok && compareClientVersions(v, minV) < 0 {
+			supported = false
+		}
+		if maxV, ok := parseClientVersion(maxVersion); ok && compareClientVersions(v, maxV) > 0 {
+			supported = false
+		}
+	}
+	if supported {
+		return nil
+	}
+
+	var bounds []string
+	if minVersion != "" {
+		bounds = append(bounds, ">= "+minVersion)
+	}
+	if maxVersion != "" {
+		bounds = append(bounds, "<= "+maxVersion)
+	}
+	return NewError(
+		ErrorCodeUpdateRequired,
+		fmt.Sprintf("client version %q is not supported, supported: %s", version, strings.Join(bounds, ", ")),
+		false,
+	)
+}
+
+// parseClientVersion parses a dotted numeric version, such as "1.4.2" or "v1.4",
+// ignoring a pre-release or build suffix after "-" or "+".
+func parseClientVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(s, ".")
+	v := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// compareClientVersions returns -1, 0 or 1 if a is older than, the same as, or newer than b.
+// The missing trailing parts are treated as 0, so "1.4" is the same as "1.4.0".
+func compareClientVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
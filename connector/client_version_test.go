@@ -0,0 +1,43 @@
+package connector_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"testing"
+)
+
+func TestCheckClientVersion(t *testing.T) {
+	tests := []struct {
+		version, minVersion, maxVersion string
+		supported                       bool
+	}{
+		{"", "", "", true},
+		{"garbage", "", "", true},
+		{"", "1.0", "", false},
+		{"garbage", "1.0", "", false},
+		{"1.4.2", "1.4.2", "1.4.2", true},
+		{"1.4", "1.4.0", "", true},
+		{"1.4.0", "", "1.4", true},
+		{"1.4.1", "1.4.2", "", false},
+		{"1.10.0", "1.9", "", true},
+		{"2.0", "", "1.99.99", false},
+		{"v1.5", "1.4", "1.6", true},
+		{"1.5.0-beta.1", "1.5", "", true},
+		{"1.5.0+build.7", "", "1.5", true},
+		{"1.-1", "1.0", "", false},
+		{"1..2", "1.0", "", false},
+		{"1.5", "garbage", "", true},
+	}
+	for _, tt := range tests {
+		err := connector.CheckClientVersion(tt.version, tt.minVersion, tt.maxVersion)
+		if supported := err == nil; supported != tt.supported {
+			t.Errorf(
+				"CheckClientVersion(%q, %q, %q) = %v, want supported %t",
+				tt.version, tt.minVersion, tt.maxVersion, err, tt.supported,
+			)
+			continue
+		}
+		if err != nil && err.Code != connector.ErrorCodeUpdateRequired {
+			t.Errorf("CheckClientVersion(%q, ...) code = %q, want %q", tt.version, err.Code, connector.ErrorCodeUpdateRequired)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package connector
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"log"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+)
+
+// connGate admits the connection attempts by the rate limits and Options.BanList, it is shared by the connectors.
+type connGate struct {
+	numRateLimitedConns int64 // numRateLimitedConns is accessed atomically and kept first for 64-bit alignment.
+
+	opts          *Options
+	ipLimiter     *ipRateLimiter // ipLimiter allows every attempt if Options.ConnRatePerIP is not set.
+	acceptLimiter *rateLimiter   // acceptLimiter allows every attempt if Options.AcceptRate is not set.
+}
+
+// newConnGate creates a connGate, the limiters are always created so that the limits can be enabled at runtime.
+func newConnGate(opts *Options) *connGate {
+	return &connGate{
+		opts:          opts,
+		ipLimiter:     newIPRateLimiter(opts.ConnRatePerIP, opts.ConnBurstPerIP, opts.Clock.Now),
+		acceptLimiter: newRateLimiter(opts.AcceptRate, opts.AcceptBurst, opts.Clock.Now),
+	}
+}
+
+// admit reports whether the connection attempt r is admitted, and replies to it with an HTTP error if not.
+func (g *connGate) admit(w http.ResponseWriter, r *http.Request) bool {
+	// Throttle the connection attempts first, as it is the cheapest check and protects the checks after it.
+	if !g.allowConn(r) {
+		atomic.AddInt64(&g.numRateLimitedConns, 1)
+		writeHTTPError(w, http.StatusTooManyRequests, errRateLimited)
+		return false
+	}
+	return g.admitPeer(w, r)
+}
+
+// admitPeer reports whether the peer of r is not banned by Options.BanList, and replies to r with an HTTP error if it is.
+// Unlike admit, it does not count against the rate limits, for the requests that are not connection attempts.
+func (g *connGate) admitPeer(w http.ResponseWriter, r *http.Request) bool {
+	// Reject the banned peers before accepting, so they can not occupy any Client resources.
+	banned, err := g.isBanned(r)
+	if err != nil {
+		log.Println("ppcserver: BanList.IsIPBanned() error:", err)
+		if g.opts.BanListFailClosed {
+			writeHTTPError(w, http.StatusServiceUnavailable, errBanListUnavailable)
+			return false
+		}
+	}
+	if banned {
+		emitAudit(g.opts.AuditSink, audit.EventTypeConnRejected, r.RemoteAddr, "banned")
+		writeHTTPError(w, http.StatusForbidden, errBanned)
+		return false
+	}
+	return true
+}
+
+// reload applies the rate limits returned by Options.ReloadRateLimits, it does nothing if it is not set.
+func (g *connGate) reload(ctx context.Context) error {
+	if g.opts.ReloadRateLimits == nil {
+		return nil
+	}
+	limits, err := g.opts.ReloadRateLimits(ctx)
+	if err != nil {
+		return err
+	}
+	g.ipLimiter.setLimit(limits.ConnRatePerIP, limits.ConnBurstPerIP)
+	g.acceptLimiter.setLimit(limits.AcceptRate, limits.AcceptBurst)
+	return nil
+}
+
+// allowConn reports whether the connection attempt r is within both the per-IP and the global rate limits.
+// The per-IP limit is checked first, so a single flooding IP does not drain the global limit.
+func (g *connGate) allowConn(r *http.Request) bool {
+	ip, err := remoteIP(r)
+	if err != nil {
+		log.Println("ppcserver: remoteIP() error:", err)
+	} else if !g.ipLimiter.allow(ip) {
+		return false
+	}
+	return g.acceptLimiter.allow()
+}
+
+// isBanned reports whether the peer of r is banned by Options.BanList, or an error from the banlist.Store,
+// which admit handles by Options.BanListFailClosed.
+func (g *connGate) isBanned(r *http.Request) (bool, error) {
+	if g.opts.BanList == nil {
+		return false, nil
+	}
+
+	ip, err := remoteIP(r)
+	if err != nil {
+		log.Println("ppcserver: remoteIP() error:", err)
+		return false, nil
+	}
+	return g.opts.BanList.IsIPBanned(r.Context(), ip)
+}
+
+// remoteIP parses the IP address of the peer from r.RemoteAddr.
+func remoteIP(r *http.Request) (netip.Addr, error) {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addrPort.Addr().Unmap(), nil
+}
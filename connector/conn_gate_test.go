@@ -0,0 +1,94 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/banlist"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net/http"
+	"net/netip"
+	"testing"
+)
+
+// unavailableBanList is a banlist.Store whose lookups always fail, as a storage outage.
+type unavailableBanList struct {
+	banlist.Store
+}
+
+func (unavailableBanList) IsIPBanned(context.Context, netip.Addr) (bool, error) {
+	return false, errors.New("ban list unavailable")
+}
+
+// dialStatus dials s and returns the HTTP status of the handshake response.
+func dialStatus(t *testing.T, s *ppctest.WebsocketServer) int {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err == nil {
+		_ = conn.Close()
+	}
+	if resp == nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestConnGateBanListFailOpen(t *testing.T) {
+	s, err := ppctest.StartWebsocketServer(connector.WithBanList(unavailableBanList{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := dialStatus(t, s); got != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", got, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestConnGateBanListFailClosed(t *testing.T) {
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithBanList(unavailableBanList{}),
+		connector.WithBanListFailClosed(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := dialStatus(t, s); got != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+}
+
+func TestConnGateReloadRateLimits(t *testing.T) {
+	limits := connector.RateLimits{}
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithRateLimitsReload(
+			func(context.Context) (connector.RateLimits, error) {
+				return limits, nil
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		if got := dialStatus(t, s); got != http.StatusSwitchingProtocols {
+			t.Fatalf("status before Reload() = %d, want %d", got, http.StatusSwitchingProtocols)
+		}
+	}
+
+	limits = connector.RateLimits{AcceptRate: 0.001, AcceptBurst: 1}
+	if err := s.Connector.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := dialStatus(t, s); got != http.StatusSwitchingProtocols {
+		t.Fatalf("status of the burst after Reload() = %d, want %d", got, http.StatusSwitchingProtocols)
+	}
+	if got := dialStatus(t, s); got != http.StatusTooManyRequests {
+		t.Fatalf("status over the limit after Reload() = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type (
+	// Connector accepts client connections over a transport protocol, and satisfies ppcserver.Component.
+	Connector interface {
+		// Start should accept client connections and block until ctx is done or the Connector fails.
+		Start(ctx context.Context) error
+		// Shutdown should stop accepting client connections and wait for the accepted ones to be closed.
+		Shutdown(ctx context.Context) error
+		// Client should return the Client with id accepted by the Connector,
+		// or false if no such Client is started and not yet exited.
+		Client(id string) (*Client, bool)
+		// Clients should return a snapshot of the Clients accepted by the Connector sorted by ID.
+		Clients() []*Client
+	}
+
+	// Factory creates a Connector customized by opts.
+	Factory func(opts ...Option) Connector
+)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register(
+		string(TransportProtocolTypeWebsocket), func(opts ...Option) Connector {
+			return NewWebsocketConnector(opts...)
+		},
+	)
+	Register(
+		string(TransportProtocolTypeSSE), func(opts ...Option) Connector {
+			return NewSSEConnector(opts...)
+		},
+	)
+}
+
+// Register makes a Connector factory available by name, so that a Connector can be created from config via New.
+// Register panics if factory is nil or Register is called twice with the same name.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("ppcserver: connector.Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("ppcserver: connector.Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a Connector by the factory registered with name.
+func New(name string, opts ...Option) (Connector, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ppcserver: unknown connector %q (forgotten Register?)", name)
+	}
+	return factory(opts...), nil
+}
+
+// Registered returns the sorted names of the registered Connector factories.
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serve runs opts.Server, on opts.Listener if set, and blocks until the server is closed for various reasons,
+// such as when the Connector's Shutdown is invoked, or when the port is already in use.
+// With useTLS, the certificates come from opts.TLSCertFile and opts.TLSKeyFile, or from opts.Server.TLSConfig if both are empty.
+// http.ErrServerClosed returns on calling http.Server.Shutdown and does not mean serving fails, so nil is returned for it.
+func serve(opts *Options, useTLS bool) error {
+	var err error
+	switch {
+	case opts.Listener != nil && useTLS:
+		err = opts.Server.ServeTLS(opts.Listener, opts.TLSCertFile, opts.TLSKeyFile)
+	case opts.Listener != nil:
+		err = opts.Server.Serve(opts.Listener)
+	case useTLS:
+		err = opts.Server.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
+	default:
+		err = opts.Server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
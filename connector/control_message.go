@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"encoding/json"
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"time"
+)
+
+const (
+	// ControlTypeServerShutdown is the type of the ShutdownNotice control message.
+	ControlTypeServerShutdown = "server_shutdown"
+	// ControlTypeError is the type of the ErrorMessage control message.
+	ControlTypeError = "error"
+	// ControlTypeFeatureFlags is the type of the FeatureFlagsMessage control message.
+	ControlTypeFeatureFlags = "feature_flags"
+	// ControlTypeRedirect is the type of the RedirectMessage control message.
+	ControlTypeRedirect = "redirect"
+)
+
+// ShutdownNotice is the control message sent to every client before its connection is closed on server shutdown,
+// so a client can reconnect to another node smoothly instead of treating it as an abrupt connection reset.
+// A client should wait for ReconnectDelayMs plus a random duration in [0, ReconnectJitterMs] before reconnecting,
+// to spread the reconnections of all the clients.
+type ShutdownNotice struct {
+	// Type is always ControlTypeServerShutdown.
+	Type string `json:"type"`
+	// ReconnectHost is the host the client should reconnect to, empty means reconnecting to the same host.
+	ReconnectHost string `json:"reconnect_host,omitempty"`
+	// ReconnectDelayMs is the minimum time in milliseconds the client should wait before reconnecting.
+	ReconnectDelayMs int64 `json:"reconnect_delay_ms"`
+	// ReconnectJitterMs is the upper bound in milliseconds of the random time added to ReconnectDelayMs.
+	ReconnectJitterMs int64 `json:"reconnect_jitter_ms"`
+}
+
+// NewShutdownNotice creates a ShutdownNotice with the reconnect hint.
+func NewShutdownNotice(reconnectHost string, delay, jitter time.Duration) *ShutdownNotice {
+	return &ShutdownNotice{
+		Type:              ControlTypeServerShutdown,
+		ReconnectHost:     reconnectHost,
+		ReconnectDelayMs:  delay.Milliseconds(),
+		ReconnectJitterMs: jitter.Milliseconds(),
+	}
+}
+
+// Marshal encodes the ShutdownNotice in JSON.
+func (n *ShutdownNotice) Marshal() ([]byte, error) {
+	return json.Marshal(n)
+}
+
+// ErrorMessage is the control message carrying an Error to the client.
+type ErrorMessage struct {
+	// Type is always ControlTypeError.
+	Type  string `json:"type"`
+	Error *Error `json:"error"`
+}
+
+// NewErrorMessage creates an ErrorMessage carrying e.
+func NewErrorMessage(e *Error) *ErrorMessage {
+	return &ErrorMessage{Type: ControlTypeError, Error: e}
+}
+
+// Marshal encodes the ErrorMessage in JSON.
+func (m *ErrorMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// FeatureFlagsMessage is the control message pushing the feature flags to the client, see WithFeatureFlags.
+// The first one after connecting is a snapshot of all the flags, and the following ones carry only the changes.
+type FeatureFlagsMessage struct {
+	// Type is always ControlTypeFeatureFlags.
+	Type string `json:"type"`
+	// Snapshot is true if Flags are all the flags, replacing the ones the client has.
+	Snapshot bool `json:"snapshot,omitempty"`
+	// Flags are the flags set, by name.
+	Flags map[string]string `json:"flags,omitempty"`
+	// Deleted are the names of the flags deleted.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// NewFeatureFlagsMessage creates a FeatureFlagsMessage carrying changes.
+func NewFeatureFlagsMessage(changes []featureflag.Change) *FeatureFlagsMessage {
+	m := &FeatureFlagsMessage{Type: ControlTypeFeatureFlags}
+	for _, change := range changes {
+		if change.Deleted {
+			m.Deleted = append(m.Deleted, change.Name)
+			continue
+		}
+		if m.Flags == nil {
+			m.Flags = make(map[string]string)
+		}
+		m.Flags[change.Name] = change.Value
+	}
+	return m
+}
+
+// Marshal encodes the FeatureFlagsMessage in JSON.
+func (m *FeatureFlagsMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// RedirectMessage is the control message sent to a client before its connection is closed,
+// telling it to reconnect to the endpoint of its region immediately, see WithRegionRedirect.
+type RedirectMessage struct {
+	// Type is always ControlTypeRedirect.
+	Type string `json:"type"`
+	// Region is the region of the client resolved by the server.
+	Region string `json:"region"`
+	// ReconnectHost is the host the client should reconnect to, as ShutdownNotice.ReconnectHost.
+	ReconnectHost string `json:"reconnect_host"`
+}
+
+// NewRedirectMessage creates a RedirectMessage to reconnectHost of region.
+func NewRedirectMessage(region, reconnectHost string) *RedirectMessage {
+	return &RedirectMessage{Type: ControlTypeRedirect, Region: region, ReconnectHost: reconnectHost}
+}
+
+// Marshal encodes the RedirectMessage in JSON.
+func (m *RedirectMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
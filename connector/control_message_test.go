@@ -0,0 +1,80 @@
+package connector_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+func TestShutdownNotice(t *testing.T) {
+	connectedCh := make(chan struct{}, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithShutdownNotice("other.example.com", time.Second, 2*time.Second),
+		connector.WithOnConnect(
+			func(*connector.Client) {
+				connectedCh <- struct{}{}
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		_ = s.Close()
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-connectedCh
+
+	closeErrCh := make(chan error, 1)
+	go func() {
+		closeErrCh <- s.Close()
+	}()
+
+	// The notice is the last message before the connection is closed by the shutdown.
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v, want the shutdown notice", err)
+	}
+	var notice connector.ShutdownNotice
+	if err := json.Unmarshal(data, &notice); err != nil {
+		t.Fatal(err)
+	}
+	want := connector.ShutdownNotice{
+		Type:              connector.ControlTypeServerShutdown,
+		ReconnectHost:     "other.example.com",
+		ReconnectDelayMs:  1000,
+		ReconnectJitterMs: 2000,
+	}
+	if notice != want {
+		t.Fatalf("notice = %+v, want %+v", notice, want)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("the connection is not closed after the notice")
+	}
+	if err := <-closeErrCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownNoticeNotSentToPeerClosing(t *testing.T) {
+	transport := ppctest.NewTransport(1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(
+			context.Background(), transport, connector.WithShutdownMessage([]byte("bye")),
+		)
+	}()
+	// The Client exits because the peer goes away, not because of a shutdown, so no notice is written.
+	_ = transport.Close()
+	<-errCh
+	if data, err := transport.Receive(context.Background()); err == nil {
+		t.Fatalf("Receive() = %q, want no message", data)
+	}
+}
@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"sync/atomic"
+)
+
+const (
+	// DisconnectReasonPeerClosed means the peer closed the connection, or the connection is lost.
+	DisconnectReasonPeerClosed DisconnectReason = iota + 1 // Starts from 1 to tell apart from no reason yet.
+	// DisconnectReasonReadTimeout means nothing arrived from the peer within the read timeout, see ErrReadTimeout.
+	DisconnectReasonReadTimeout
+	// DisconnectReasonKicked means the server closed the Client, such as for being a slow consumer,
+	// exceeding its bandwidth quota, or by Client.Close.
+	DisconnectReasonKicked
+	// DisconnectReasonServerShutdown means the server is shutting down.
+	DisconnectReasonServerShutdown
+	// DisconnectReasonWriteError means writing to the peer failed.
+	DisconnectReasonWriteError
+	// DisconnectReasonAuthTimeout means the Client is not authorized within ClientOptions.AuthTimeout.
+	DisconnectReasonAuthTimeout
+
+	numDisconnectReasons = int(DisconnectReasonAuthTimeout) + 1
+)
+
+var numDisconnects [numDisconnectReasons]int64 // numDisconnects[r] counts the Clients disconnected for r.
+
+type (
+	// DisconnectReason is the cause of a Client being closed, the first cause detected wins.
+	DisconnectReason int32
+
+	// DisconnectHook is invoked once per Client after it is closed, with the cause.
+	DisconnectHook func(c *Client, reason DisconnectReason)
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectReasonPeerClosed:
+		return "peer closed"
+	case DisconnectReasonReadTimeout:
+		return "read timeout"
+	case DisconnectReasonKicked:
+		return "kicked"
+	case DisconnectReasonServerShutdown:
+		return "server shutdown"
+	case DisconnectReasonWriteError:
+		return "write error"
+	case DisconnectReasonAuthTimeout:
+		return "auth timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// NumDisconnects returns the number of Clients disconnected for reason since the start.
+func NumDisconnects(reason DisconnectReason) int64 {
+	if reason <= 0 || int(reason) >= numDisconnectReasons {
+		return 0
+	}
+	return atomic.LoadInt64(&numDisconnects[reason])
+}
+
+// DisconnectReason returns why the Client is closed, or 0 if it is not closed yet.
+func (c *Client) DisconnectReason() DisconnectReason {
+	return DisconnectReason(atomic.LoadInt32(&c.disconnectReason))
+}
+
+// setDisconnectReason records reason unless a reason is already recorded, as the first cause detected wins.
+// It reports whether reason is recorded.
+func (c *Client) setDisconnectReason(reason DisconnectReason) bool {
+	return atomic.CompareAndSwapInt32(&c.disconnectReason, 0, int32(reason))
+}
+
+// readErrorReason returns the DisconnectReason of err returned from reading the transport.
+func readErrorReason(err error) DisconnectReason {
+	if errors.Is(err, ErrReadTimeout) {
+		return DisconnectReasonReadTimeout
+	}
+	return DisconnectReasonPeerClosed
+}
+
+// reportDisconnect counts the Client by its DisconnectReason, emits an audit.EventTypeClientDisconnected with it
+// to ClientOptions.AuditSink, and invokes ClientOptions.OnDisconnect.
+func (c *Client) reportDisconnect() {
+	reason := c.DisconnectReason()
+	if reason > 0 && int(reason) < numDisconnectReasons {
+		atomic.AddInt64(&numDisconnects[reason], 1)
+	}
+	c.auditClient(audit.EventTypeClientDisconnected, reason.String())
+	if c.opts.OnDisconnect != nil {
+		c.opts.OnDisconnect(c, reason)
+	}
+}
@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// clientToServerKeyInfo and serverToClientKeyInfo bind the derived keys to their directions, see hkdf.New,
+	// so a message sent by the server can not be reflected back to it as a valid message from the peer.
+	clientToServerKeyInfo = "ppcserver payload encryption v2 client to server"
+	serverToClientKeyInfo = "ppcserver payload encryption v2 server to client"
+)
+
+var errNonceExhausted = errors.New("ppcserver: encryption nonce exhausted")
+
+// encryptedTransport wraps a Transport to encrypt every message with AES-256-GCM below the encoding,
+// for the deployments that must keep the payload encrypted independently of the TLS termination.
+//
+// The keys are agreed by a handshake before any other message: the peer sends its X25519 public key as the first
+// message, the server replies with its own, and both derive a key per direction from the shared secret
+// with HKDF-SHA256, salted with the peer public key followed by the server public key.
+// Every message afterwards is the sealed payload only, the nonce is the sequence number of the message
+// in its direction, starting from 0, so a replayed, reordered or dropped message fails the authentication.
+type encryptedTransport struct {
+	Transport
+	readAEAD  cipher.AEAD // readAEAD opens the messages from the peer with the client to server key.
+	writeAEAD cipher.AEAD // writeAEAD seals the messages to the peer with the server to client key.
+	readSeq   uint64      // readSeq is the sequence number of the next message to read, only accessed by the reader.
+
+	writeMu  sync.Mutex // writeMu guards writeSeq, and keeps the messages written in the order of their sequence numbers.
+	writeSeq uint64
+}
+
+// newEncryptedTransport performs the handshake over t and returns the wrapped Transport.
+// serverPrivateKey is the X25519 private key of the server, an ephemeral one is generated if it is nil.
+// A static key lets the peer pin the server public key to detect an active man-in-the-middle,
+// while an ephemeral key gives forward secrecy but protects against passive eavesdropping only.
+func newEncryptedTransport(t Transport, serverPrivateKey []byte) (*encryptedTransport, error) {
+	if serverPrivateKey == nil {
+		serverPrivateKey = make([]byte, curve25519.ScalarSize)
+		if _, err := io.ReadFull(rand.Reader, serverPrivateKey); err != nil {
+			return nil, fmt.Errorf("ppcserver: generate X25519 private key error: %w", err)
+		}
+	}
+	serverPublicKey, err := curve25519.X25519(serverPrivateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("ppcserver: X25519 public key error: %w", err)
+	}
+
+	peerPublicKey, err := t.Read()
+	if err != nil {
+		return nil, fmt.Errorf("ppcserver: read peer public key error: %w", err)
+	}
+	// X25519 rejects a peer public key of the wrong size, or one resulting in the all-zero shared secret.
+	sharedSecret, err := curve25519.X25519(serverPrivateKey, peerPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("ppcserver: X25519 shared secret error: %w", err)
+	}
+	if err := t.Write(serverPublicKey); err != nil {
+		return nil, fmt.Errorf("ppcserver: write server public key error: %w", err)
+	}
+
+	salt := append(append([]byte{}, peerPublicKey...), serverPublicKey...)
+	readAEAD, err := newEncryptionAEAD(sharedSecret, salt, clientToServerKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	writeAEAD, err := newEncryptionAEAD(sharedSecret, salt, serverToClientKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedTransport{Transport: t, readAEAD: readAEAD, writeAEAD: writeAEAD}, nil
+}
+
+// newEncryptionAEAD derives an AES-256-GCM key from sharedSecret with HKDF-SHA256 for the direction named by info.
+func newEncryptionAEAD(sharedSecret, salt []byte, info string) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("ppcserver: derive encryption key error: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sequenceNonce returns the GCM nonce of the message numbered seq, which is seq in big-endian padded with zeros.
+func sequenceNonce(seq uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+// Read reads a message from the wrapped Transport and decrypts it as the next message in sequence.
+// A message failing the authentication, such as a replayed or reordered one, is returned as an error,
+// which closes the connection.
+func (t *encryptedTransport) Read() ([]byte, error) {
+	data, err := t.Transport.Read()
+	if err != nil {
+		return nil, err
+	}
+	if t.readSeq == math.MaxUint64 {
+		return nil, errNonceExhausted
+	}
+	plaintext, err := t.readAEAD.Open(nil, sequenceNonce(t.readSeq), data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ppcserver: decrypt message %d error: %w", t.readSeq, err)
+	}
+	t.readSeq++
+	return plaintext, nil
+}
+
+// Write encrypts data as the next message in sequence and writes it to the wrapped Transport.
+// The sequence number advances even if the write fails, as the connection is closed on any write error.
+func (t *encryptedTransport) Write(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if t.writeSeq == math.MaxUint64 {
+		return errNonceExhausted
+	}
+	sealed := t.writeAEAD.Seal(nil, sequenceNonce(t.writeSeq), data, nil)
+	t.writeSeq++
+	return t.Transport.Write(sealed)
+}
+
+// Ping forwards to the wrapped Transport if it is a Pinger, the pings are not encrypted.
+func (t *encryptedTransport) Ping() error {
+	if pinger, ok := t.Transport.(Pinger); ok {
+		return pinger.Ping()
+	}
+	return nil
+}
+
+// RTT forwards to the wrapped Transport if it is a Pinger.
+func (t *encryptedTransport) RTT() time.Duration {
+	if pinger, ok := t.Transport.(Pinger); ok {
+		return pinger.RTT()
+	}
+	return 0
+}
@@ -0,0 +1,227 @@
+package connector_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"testing"
+	"time"
+)
+
+// encryptionPeer is the client side of the payload encryption, implemented from the documented protocol.
+type encryptionPeer struct {
+	t                  *ppctest.Transport
+	sendAEAD, recvAEAD cipher.AEAD
+	sendSeq, recvSeq   uint64
+}
+
+func newPeerAEAD(t *testing.T, secret, salt []byte, info string) cipher.AEAD {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func nonce(seq uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], seq)
+	return n
+}
+
+// startEncryption performs the handshake between a new encryptedTransport and an encryptionPeer.
+func startEncryption(t *testing.T) (connector.Transport, *encryptionPeer) {
+	t.Helper()
+	mt := ppctest.NewTransport(16)
+	t.Cleanup(func() { _ = mt.Close() })
+
+	type result struct {
+		transport connector.Transport
+		err       error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		et, err := connector.NewEncryptedTransport(mt, nil)
+		resultCh <- result{et, err}
+	}()
+
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(priv); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Send(pub); err != nil {
+		t.Fatal(err)
+	}
+	serverPub, err := receive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-resultCh
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	secret, err := curve25519.X25519(priv, serverPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := append(append([]byte{}, pub...), serverPub...)
+	return r.transport, &encryptionPeer{
+		t:        mt,
+		sendAEAD: newPeerAEAD(t, secret, salt, "ppcserver payload encryption v2 client to server"),
+		recvAEAD: newPeerAEAD(t, secret, salt, "ppcserver payload encryption v2 server to client"),
+	}
+}
+
+func receive(mt *ppctest.Transport) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return mt.Receive(ctx)
+}
+
+// seal seals data as the next message from the peer.
+func (p *encryptionPeer) seal(data []byte) []byte {
+	sealed := p.sendAEAD.Seal(nil, nonce(p.sendSeq), data, nil)
+	p.sendSeq++
+	return sealed
+}
+
+// open receives and opens the next message to the peer.
+func (p *encryptionPeer) open(t *testing.T) []byte {
+	t.Helper()
+	sealed, err := receive(p.t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.recvAEAD.Open(nil, nonce(p.recvSeq), sealed, nil)
+	if err != nil {
+		t.Fatalf("open message %d: %v", p.recvSeq, err)
+	}
+	p.recvSeq++
+	return data
+}
+
+func TestEncryptedTransportRoundTrip(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	for _, msg := range []string{"hello", "", "world"} {
+		if err := peer.t.Send(peer.seal([]byte(msg))); err != nil {
+			t.Fatal(err)
+		}
+		got, err := et.Read()
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		if string(got) != msg {
+			t.Fatalf("Read() = %q, want %q", got, msg)
+		}
+
+		if err := et.Write([]byte("re: " + msg)); err != nil {
+			t.Fatal(err)
+		}
+		if got := peer.open(t); string(got) != "re: "+msg {
+			t.Fatalf("peer got %q, want %q", got, "re: "+msg)
+		}
+	}
+}
+
+func TestEncryptedTransportRejectsTampered(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	sealed := peer.seal([]byte("hello"))
+	sealed[0] ^= 1
+	if err := peer.t.Send(sealed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := et.Read(); err == nil {
+		t.Fatal("Read() of a tampered message succeeded")
+	}
+}
+
+func TestEncryptedTransportRejectsReflected(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	// A message sent by the server, reflected back as the first message from the peer with the same sequence number.
+	if err := et.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := receive(peer.t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.t.Send(sealed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := et.Read(); err == nil {
+		t.Fatal("Read() of a reflected message succeeded")
+	}
+}
+
+func TestEncryptedTransportRejectsReplayed(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	sealed := peer.seal([]byte("hello"))
+	for i := 0; i < 2; i++ {
+		if err := peer.t.Send(sealed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := et.Read(); err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if _, err := et.Read(); err == nil {
+		t.Fatal("Read() of a replayed message succeeded")
+	}
+}
+
+func TestEncryptedTransportRejectsReordered(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	first, second := peer.seal([]byte("first")), peer.seal([]byte("second"))
+	if err := peer.t.Send(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.t.Send(first); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := et.Read(); err == nil {
+		t.Fatalf("Read() of a reordered message succeeded: %q", got)
+	}
+}
+
+func TestEncryptedTransportWritesInSequence(t *testing.T) {
+	et, peer := startEncryption(t)
+
+	// The peer can only open the messages in the order they are written, each with the next sequence number.
+	for i := 0; i < 3; i++ {
+		msg := []byte{byte(i)}
+		if err := et.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		if got := peer.open(t); !bytes.Equal(got, msg) {
+			t.Fatalf("peer got %v, want %v", got, msg)
+		}
+	}
+}
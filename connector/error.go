@@ -0,0 +1,81 @@
+package connector
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// ErrorCodeRateLimited means the request exceeds a rate limit, retry later.
+	ErrorCodeRateLimited ErrorCode = "rate_limited"
+	// ErrorCodeBanned means the peer is banned.
+	ErrorCodeBanned ErrorCode = "banned"
+	// ErrorCodeUnsupportedProtocol means none of the protocol versions requested by the peer is supported.
+	ErrorCodeUnsupportedProtocol ErrorCode = "unsupported_protocol"
+	// ErrorCodeSessionNotFound means the SSE session is not open, open a new one.
+	ErrorCodeSessionNotFound ErrorCode = "session_not_found"
+	// ErrorCodeServerFull means the server reaches its maximum number of clients, retry later or elsewhere.
+	ErrorCodeServerFull ErrorCode = "server_full"
+	// ErrorCodeUpdateRequired means the client version is not supported, update the client before connecting again.
+	ErrorCodeUpdateRequired ErrorCode = "update_required"
+	// ErrorCodeUnavailable means a dependency of the server is unavailable for now, retry later.
+	ErrorCodeUnavailable ErrorCode = "unavailable"
+)
+
+type (
+	// ErrorCode identifies the kind of an Error, it is stable for clients to switch on.
+	ErrorCode string
+
+	// Error is the framework-wide error model that reaches clients in a structured form,
+	// either as the JSON body of a rejected HTTP request, or in an ErrorMessage before the connection is closed.
+	Error struct {
+		Code    ErrorCode `json:"code"`
+		Message string    `json:"message"`
+		// Retryable tells the client whether retrying the same request later may succeed.
+		Retryable bool `json:"retryable"`
+	}
+)
+
+var (
+	errRateLimited = &Error{
+		Code: ErrorCodeRateLimited, Message: "too many connection attempts", Retryable: true,
+	}
+	errBanned = &Error{
+		Code: ErrorCodeBanned, Message: "banned", Retryable: false,
+	}
+	errBanListUnavailable = &Error{
+		Code: ErrorCodeUnavailable, Message: "ban list unavailable", Retryable: true,
+	}
+	errSessionNotFound = &Error{
+		Code: ErrorCodeSessionNotFound, Message: "session not found", Retryable: false,
+	}
+)
+
+// NewError creates an Error.
+func NewError(code ErrorCode, message string, retryable bool) *Error {
+	return &Error{Code: code, Message: message, Retryable: retryable}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return "ppcserver: " + e.Message
+}
+
+// writeHTTPError replies to the request with status and e as the JSON body of an ErrorMessage.
+// Note that browsers do not expose the response body of a failed WebSocket handshake,
+// it is for the non-browser clients and debugging.
+func writeHTTPError(w http.ResponseWriter, status int, e *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(NewErrorMessage(e))
+}
+
+// writeError writes e in an ErrorMessage to the peer, the write is best-effort as the connection is about to close.
+func writeError(t Transport, e *Error) {
+	data, err := NewErrorMessage(e).Marshal()
+	if err != nil {
+		return
+	}
+	_ = t.Write(data)
+}
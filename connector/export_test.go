@@ -0,0 +1,51 @@
+package connector
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// Export the unexported identifiers for the tests in package connector_test,
+// which use ppctest and so can not be in package connector without an import cycle.
+var NewEncryptedTransport = func(t Transport, serverPrivateKey []byte) (Transport, error) {
+	return newEncryptedTransport(t, serverPrivateKey)
+}
+
+var NewSSETransport = func(w http.ResponseWriter, f http.Flusher, conn net.Conn, writeTimeout time.Duration) Transport {
+	return newSSETransport(w, f, conn, writeTimeout)
+}
+
+var IsTimeout = isTimeout
+
+var CheckClientVersion = checkClientVersion
+
+var NewClientID = newClientID
+
+// NewIPRateLimiter returns the allow method of a new ipRateLimiter.
+var NewIPRateLimiter = func(rate float64, burst int, now func() time.Time) func(ip netip.Addr) bool {
+	return newIPRateLimiter(rate, burst, now).allow
+}
+
+// ClientRegistry exposes clientRegistry for the benchmarks, Register and Lookup do what StartClient and ClientByID do.
+type ClientRegistry struct {
+	r *clientRegistry
+}
+
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{r: newClientRegistry()}
+}
+
+func (r *ClientRegistry) Register(id string) (unregister func()) {
+	c := &Client{id: id}
+	r.r.add(c)
+	return func() {
+		r.r.remove(c)
+	}
+}
+
+func (r *ClientRegistry) Lookup(id string) bool {
+	_, ok := r.r.get(id)
+	return ok
+}
@@ -0,0 +1,58 @@
+package connector
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"log"
+)
+
+// FeatureFlags returns the feature flags for the handlers serving the Client to consult,
+// or nil if not set via WithFeatureFlags.
+func (c *Client) FeatureFlags() featureflag.FeatureFlags {
+	return c.opts.FeatureFlags
+}
+
+// watchFeatureFlags queues the changes of ClientOptions.FeatureFlags to be written to the peer until stop is called,
+// it does nothing if FeatureFlags is not set. The snapshot is written by writeLoop, see writeFeatureFlagsSnapshot.
+func (c *Client) watchFeatureFlags() (stop func()) {
+	if c.opts.FeatureFlags == nil {
+		return func() {}
+	}
+	return c.opts.FeatureFlags.Watch(
+		func(changes []featureflag.Change) {
+			c.writeFeatureFlags(NewFeatureFlagsMessage(changes))
+		},
+	)
+}
+
+// writeFeatureFlagsSnapshot writes a snapshot of ClientOptions.FeatureFlags to the transport, if set.
+// It is called by writeLoop before any queued message, so the snapshot does not depend on the write queue having room,
+// and is taken after watchFeatureFlags so no change is missed, a change queued before it is sent twice harmlessly.
+func (c *Client) writeFeatureFlagsSnapshot() error {
+	ff := c.opts.FeatureFlags
+	if ff == nil {
+		return nil
+	}
+	data, err := (&FeatureFlagsMessage{Type: ControlTypeFeatureFlags, Snapshot: true, Flags: ff.All()}).Marshal()
+	if err != nil {
+		log.Println("ppcserver: FeatureFlagsMessage.Marshal() error:", err)
+		return nil
+	}
+	c.traceMessage(TraceDirectionOut, data)
+	if err := c.transport.Write(data); err != nil {
+		return err
+	}
+	c.addBytesOut(len(data))
+	return nil
+}
+
+// writeFeatureFlags queues m to be written to the peer, a failure is only logged.
+func (c *Client) writeFeatureFlags(m *FeatureFlagsMessage) {
+	data, err := m.Marshal()
+	if err != nil {
+		log.Println("ppcserver: FeatureFlagsMessage.Marshal() error:", err)
+		return
+	}
+	if err := c.Write(data); err != nil && err != ErrClientClosed {
+		log.Printf("ppcserver: Client(%s) write feature flags error: %v", c.id, err)
+	}
+}
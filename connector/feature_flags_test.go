@@ -0,0 +1,42 @@
+package connector_test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+func TestFeatureFlagsSnapshotWithMinimalWriteBuffer(t *testing.T) {
+	ff := featureflag.NewMemoryFlags(map[string]string{"new_matchmaker": "true"})
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithFeatureFlags(ff),
+		connector.WithClientOptions(connector.WithWriteBuffer(1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v, want the snapshot", err)
+	}
+	var m connector.FeatureFlagsMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != connector.ControlTypeFeatureFlags || !m.Snapshot || m.Flags["new_matchmaker"] != "true" {
+		t.Fatalf("message = %s, want the snapshot of the flags", data)
+	}
+}
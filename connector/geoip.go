@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// RegionTagPrefix prefixes the region of a Client in its tags, such as "region:eu-west",
+// for BroadcastToTag and the matchmaking to target the Clients in a region.
+const RegionTagPrefix = "region:"
+
+var (
+	numRegionRedirects int64
+
+	// clientsByRegion counts the current Clients by region, guarded by clientsByRegionMu.
+	clientsByRegionMu sync.Mutex
+	clientsByRegion   = make(map[string]int)
+)
+
+type (
+	// GeoIPResolver resolves the region of a peer by its IP address at connect time,
+	// such as by a MaxMind database lookup. See WithGeoIP.
+	// Implementations must be safe for concurrent use.
+	GeoIPResolver interface {
+		// Region should return the region of ip, or an empty string if unknown.
+		Region(ctx context.Context, ip netip.Addr) (string, error)
+	}
+
+	// GeoIPResolverFunc is an adapter to use a function as a GeoIPResolver.
+	GeoIPResolverFunc func(ctx context.Context, ip netip.Addr) (string, error)
+
+	// PrefixRegions is a GeoIPResolver by a static table from IP prefixes to regions,
+	// such as the prefixes of the cloud providers or the private networks in a test environment.
+	// The most specific prefix containing the IP wins.
+	PrefixRegions map[netip.Prefix]string
+)
+
+// Region calls f(ctx, ip).
+func (f GeoIPResolverFunc) Region(ctx context.Context, ip netip.Addr) (string, error) {
+	return f(ctx, ip)
+}
+
+func (p PrefixRegions) Region(_ context.Context, ip netip.Addr) (string, error) {
+	region, bits := "", -1
+	for prefix, r := range p {
+		if prefix.Bits() > bits && prefix.Contains(ip) {
+			region, bits = r, prefix.Bits()
+		}
+	}
+	return region, nil
+}
+
+// Region returns the region of the peer resolved at connect time, or an empty string if unknown, see WithGeoIP.
+func (c *Client) Region() string {
+	return c.opts.Region
+}
+
+// NumClientsByRegion returns the number of the current Clients on this node by region,
+// the Clients of an unknown region are counted under an empty string.
+func NumClientsByRegion() map[string]int {
+	clientsByRegionMu.Lock()
+	defer clientsByRegionMu.Unlock()
+	snapshot := make(map[string]int, len(clientsByRegion))
+	for region, n := range clientsByRegion {
+		snapshot[region] = n
+	}
+	return snapshot
+}
+
+// NumRegionRedirects returns the number of Clients redirected to the endpoints of their regions since the start.
+func NumRegionRedirects() int64 {
+	return atomic.LoadInt64(&numRegionRedirects)
+}
+
+// Regions returns the sorted regions with any current Client on this node.
+func Regions() []string {
+	clientsByRegionMu.Lock()
+	defer clientsByRegionMu.Unlock()
+	regions := make([]string, 0, len(clientsByRegion))
+	for region := range clientsByRegion {
+		if region != "" {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// countRegion adds delta to the number of the current Clients in region.
+func countRegion(region string, delta int) {
+	clientsByRegionMu.Lock()
+	defer clientsByRegionMu.Unlock()
+	clientsByRegion[region] += delta
+	if clientsByRegion[region] <= 0 {
+		delete(clientsByRegion, region)
+	}
+}
+
+// resolveRegion returns the region of the peer of r by Options.GeoIP,
+// or an empty string if it is not set or fails, as the region is only a hint.
+func resolveRegion(opts *Options, r *http.Request) string {
+	if opts.GeoIP == nil {
+		return ""
+	}
+	ip, err := remoteIP(r)
+	if err != nil {
+		log.Println("ppcserver: remoteIP() error:", err)
+		return ""
+	}
+	region, err := opts.GeoIP.Region(r.Context(), ip)
+	if err != nil {
+		log.Println("ppcserver: GeoIPResolver.Region() error:", err)
+		return ""
+	}
+	return region
+}
+
+// regionRedirect returns the RedirectMessage to the endpoint of ClientOptions.Region,
+// or nil if the region is ClientOptions.LocalRegion, unknown, or has no endpoint.
+func regionRedirect(o *ClientOptions) *RedirectMessage {
+	region := o.Region
+	if region == "" || region == o.LocalRegion {
+		return nil
+	}
+	host, ok := o.RegionEndpoints[region]
+	if !ok {
+		return nil
+	}
+	return NewRedirectMessage(region, host)
+}
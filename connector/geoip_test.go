@@ -0,0 +1,45 @@
+package connector_test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRegionRedirectWhenFull(t *testing.T) {
+	connector.SetMaxClients(0)
+	defer connector.SetMaxClients(math.MaxInt32)
+
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithGeoIP(connector.PrefixRegions{netip.MustParsePrefix("127.0.0.0/8"): "eu-west"}),
+		connector.WithRegionRedirect("us-east", map[string]string{"eu-west": "eu.example.com"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m connector.RedirectMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != connector.ControlTypeRedirect || m.ReconnectHost != "eu.example.com" {
+		t.Fatalf("message = %s, want the redirect to eu.example.com", data)
+	}
+}
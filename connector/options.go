@@ -1,7 +1,16 @@
 package connector
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/analytics"
+	"github.com/pom-pom-crafts/ppcserver/audit"
+	"github.com/pom-pom-crafts/ppcserver/banlist"
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"golang.org/x/crypto/acme/autocert"
+	"net"
 	"net/http"
 	"time"
 )
@@ -10,6 +19,27 @@ type (
 	// Option is a function to apply various configurations to customize a connector Component.
 	Option func(o *Options)
 
+	// RateLimits are the connection rate limits of a connector, see WithConnRateLimitPerIP and WithAcceptRateLimit
+	// for the meaning of each field, a rate <= 0 disables the limit.
+	RateLimits struct {
+		ConnRatePerIP  float64
+		ConnBurstPerIP int
+		AcceptRate     float64
+		AcceptBurst    int
+	}
+
+	// Clock tells the current time to the time-dependent parts of a connector, such as the rate limits,
+	// so that the tests can control the time, such as by ppctest.Clock.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// systemClock is the Clock of the system time.
+	systemClock struct{}
+
+	// RateLimitsFunc returns the RateLimits to apply when the connector is reloaded, see WithRateLimitsReload.
+	RateLimitsFunc func(ctx context.Context) (RateLimits, error)
+
 	// Options hold the configurable parts of a connector Component.
 	Options struct {
 		// WriteTimeout is the maximum time of write message operation.
@@ -17,6 +47,60 @@ type (
 		// Default is 1 second if not set via WithWriteTimeout.
 		WriteTimeout time.Duration
 
+		// WriteTimeoutRTTMultiplier and MaxWriteTimeout adapt the write timeout of each client to its measured RTT,
+		// see WithAdaptiveWriteTimeout. The write timeout is not adaptive if not set.
+		WriteTimeoutRTTMultiplier float64
+		MaxWriteTimeout           time.Duration
+
+		// ReadTimeout is how long a client may stay silent, the deadline is extended on every message and pong,
+		// so with the pings enabled only a dead peer reaches it. It should be well above PingInterval.
+		// Default is 60 seconds if not set via WithReadTimeout, a non-positive value disables the read timeout.
+		ReadTimeout time.Duration
+
+		// PingInterval is how often a ping is sent to each client to measure its round-trip time.
+		// Default is 25 seconds if not set via WithPingInterval, a non-positive value disables the pings.
+		PingInterval time.Duration
+
+		// WriteCoalesceInterval and WriteCoalesceMaxBytes control the coalescing of outgoing messages.
+		// No coalescing is done if not set via WithWriteCoalescing.
+		WriteCoalesceInterval time.Duration
+		WriteCoalesceMaxBytes int
+
+		// SlowConsumerPolicy decides what happens to a client once it is detected as a slow consumer,
+		// which is when its write queue is full or a write to it times out.
+		// Default is SlowConsumerPolicyKick if not set via WithSlowConsumerPolicy.
+		SlowConsumerPolicy SlowConsumerPolicy
+
+		// OnSlowConsumer is invoked when a client is first detected as a slow consumer.
+		// Optionally set via WithOnSlowConsumer.
+		OnSlowConsumer SlowConsumerHook
+
+		// OnConnect is invoked when a client is connected, before any message is read from it.
+		// Optionally set via WithOnConnect.
+		OnConnect ClientHook
+
+		// OnDisconnect is invoked after a client is closed, with the cause.
+		// Optionally set via WithOnDisconnect.
+		OnDisconnect DisconnectHook
+
+		// OnMessage is invoked with every message read from a client, in order per client.
+		// Optionally set via WithOnMessage.
+		OnMessage MessageHandler
+
+		// ClientOptions are applied to every client after the ones derived from these Options,
+		// for tuning the per-client behavior such as WithWriteBuffer. Optionally set via WithClientOptions.
+		ClientOptions []ClientOption
+
+		// OutboundInterceptors are applied in order to the data written to every client.
+		// Optionally set via WithOutboundInterceptors.
+		OutboundInterceptors []OutboundInterceptor
+
+		// BandwidthQuota, BandwidthBurst and BandwidthPolicy limit the bytes per second read from each client.
+		// No quota if not set via WithBandwidthQuota.
+		BandwidthQuota  int
+		BandwidthBurst  int
+		BandwidthPolicy BandwidthPolicy
+
 		// MaxMessageSize is the maximum allowed message size in bytes received from the client.
 		// Default is 4096 bytes (4KB) if not set via WithMaxMessageSize.
 		MaxMessageSize int64
@@ -26,11 +110,20 @@ type (
 		// See net.Dial for details of the address format.
 		Addr string
 
+		// Listener optionally specifies an already bound listener to accept connections on, Addr is ignored if set.
+		// This is useful for listening on an ephemeral port, whose address is known only after binding.
+		Listener net.Listener
+
 		// WebsocketPath is the URL path to accept WebSocket connections.
 		// This option only applies to WebsocketConnector.
 		// Default is "/" if not set via WithWebsocketPath.
 		WebsocketPath string
 
+		// SSEPath is the URL path to open the SSE sessions and post the messages.
+		// This option only applies to SSEConnector.
+		// Default is "/sse" if not set via WithSSEPath.
+		SSEPath string
+
 		// TLSCertFile is the path to TLS cert file.
 		// This option only applies to WebsocketConnector.
 		TLSCertFile string
@@ -39,25 +132,134 @@ type (
 		// This option only applies to WebsocketConnector.
 		TLSKeyFile string
 
+		// AutoTLSDomains are the domains to obtain TLS certificates for automatically from Let's Encrypt,
+		// TLSCertFile and TLSKeyFile are ignored if set.
+		// This option only applies to WebsocketConnector.
+		AutoTLSDomains []string
+
+		// AutoTLSCache stores the obtained certificates across restarts, to stay within the Let's Encrypt rate limits.
+		// This option only applies to WebsocketConnector.
+		// Default is autocert.DirCache("autocert-cache") if not set via WithAutoTLSCache.
+		AutoTLSCache autocert.Cache
+
+		// AutoTLSHTTPAddr is the TCP address to serve the ACME HTTP-01 challenge on,
+		// which also redirects the plain HTTP requests to HTTPS.
+		// This option only applies to WebsocketConnector.
+		// Default is ":http" (port 80) if not set via WithAutoTLSHTTPAddr, an empty string disables it.
+		AutoTLSHTTPAddr string
+
+		// ClientCAs are the certificate authorities to verify the client certificates with,
+		// and ClientAuth is the policy of requesting and verifying the client certificates.
+		// No client certificate is requested if not set via WithClientCertAuth.
+		// These options only apply to WebsocketConnector with TLS enabled.
+		ClientCAs  *x509.CertPool
+		ClientAuth tls.ClientAuthType
+
+		// PayloadEncryption enables the encryption of every message with a key agreed in a handshake,
+		// and EncryptionPrivateKey is the optional static X25519 private key of the server.
+		// No encryption is done if not set via WithPayloadEncryption.
+		// These options only apply to WebsocketConnector.
+		PayloadEncryption    bool
+		EncryptionPrivateKey []byte
+
 		ServeMux *http.ServeMux
 
 		Server *http.Server
 
 		Upgrader *websocket.Upgrader
+
+		// ProtocolVersions are the protocol versions supported by the server, in the order of preference.
+		// WebsocketConnector negotiates the version via the Sec-WebSocket-Protocol header,
+		// and rejects the clients requesting none of them.
+		// This option is ignored by WebsocketConnector if Upgrader.Subprotocols is set.
+		// Default is []string{ProtocolVersion1} if not set via WithProtocolVersions.
+		ProtocolVersions []string
+
+		// BanList is consulted before accepting a connection, a peer whose IP is banned is rejected.
+		// No ban check is performed if not set via WithBanList.
+		BanList banlist.Store
+
+		// BanListFailClosed rejects every connection with 503 Service Unavailable while BanList fails,
+		// otherwise the peers are admitted as not banned, so an unavailable storage does not lock out every client.
+		// Default is false (fail open) if not set via WithBanListFailClosed.
+		BanListFailClosed bool
+
+		// AuditSink receives the audit events, such as the kicked clients and the rejected banned peers.
+		// No audit event is emitted if not set via WithAuditSink.
+		AuditSink audit.Sink
+
+		// ConnRatePerIP is the maximum rate of connection attempts per second from a single IP address,
+		// with bursts of at most ConnBurstPerIP attempts.
+		// No per-IP limit is applied if not set via WithConnRateLimitPerIP.
+		ConnRatePerIP  float64
+		ConnBurstPerIP int
+
+		// AcceptRate is the maximum rate of connection attempts per second accepted from all peers,
+		// with bursts of at most AcceptBurst attempts.
+		// No global limit is applied if not set via WithAcceptRateLimit.
+		AcceptRate  float64
+		AcceptBurst int
+
+		// Clock tells the current time to the rate limits.
+		// Default is the system time if not set via WithClock.
+		Clock Clock
+
+		// ReloadRateLimits returns the rate limits applied by the Reload of the connector, such as on SIGHUP.
+		// The rate limits are not reloadable if not set via WithRateLimitsReload.
+		ReloadRateLimits RateLimitsFunc
+
+		// ShutdownNotice is sent to every client before its connection is closed on server shutdown.
+		// No notice is sent if not set via WithShutdownNotice.
+		ShutdownNotice *ShutdownNotice
+
+		// MinClientVersion and MaxClientVersion are the range of the client build versions supported,
+		// the clients out of the range are closed with an Error of ErrorCodeUpdateRequired.
+		// No version is checked if not set via WithClientVersionRange.
+		MinClientVersion string
+		MaxClientVersion string
+
+		// FeatureFlags are consulted by the handlers via Client.FeatureFlags and pushed to the clients.
+		// No flag is pushed if not set via WithFeatureFlags.
+		FeatureFlags featureflag.FeatureFlags
+
+		// SessionAnalytics receives a SessionSummaryEvent of every client when it disconnects.
+		// No session summary is emitted if not set via WithSessionAnalytics.
+		SessionAnalytics analytics.Emitter
+
+		// GeoIP resolves the region of every client at connect time, see Client.Region.
+		// No region is resolved if not set via WithGeoIP.
+		GeoIP GeoIPResolver
+
+		// LocalRegion is the region this server serves, and RegionEndpoints are the hosts serving the other regions.
+		// No client is redirected if not set via WithRegionRedirect.
+		LocalRegion     string
+		RegionEndpoints map[string]string
 	}
 )
 
 func defaultOptions() *Options {
 	return &Options{
-		WebsocketPath:  "/",
-		WriteTimeout:   1 * time.Second,
-		MaxMessageSize: 4096,
-		ServeMux:       http.DefaultServeMux,
-		Server:         &http.Server{},
-		Upgrader:       &websocket.Upgrader{},
+		WebsocketPath:    "/",
+		SSEPath:          "/sse",
+		WriteTimeout:     1 * time.Second,
+		ReadTimeout:      60 * time.Second,
+		PingInterval:     25 * time.Second,
+		MaxMessageSize:   4096,
+		AutoTLSCache:     autocert.DirCache("autocert-cache"),
+		AutoTLSHTTPAddr:  ":http",
+		ServeMux:         http.DefaultServeMux,
+		Server:           &http.Server{},
+		Upgrader:         &websocket.Upgrader{},
+		ProtocolVersions: []string{ProtocolVersion1},
+		Clock:            systemClock{},
 	}
 }
 
+// Now returns time.Now().
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
 // WithAddr is an Option to set the TCP address for the server to listen on.
 func WithAddr(a string) Option {
 	return func(o *Options) {
@@ -68,6 +270,13 @@ func WithAddr(a string) Option {
 	}
 }
 
+// WithListener is an Option to set an already bound listener for the server to accept connections on.
+func WithListener(ln net.Listener) Option {
+	return func(o *Options) {
+		o.Listener = ln
+	}
+}
+
 // WithWebsocketPath is an Option to set the URL path for accepting WebSocket connections.
 func WithWebsocketPath(p string) Option {
 	return func(o *Options) {
@@ -75,6 +284,13 @@ func WithWebsocketPath(p string) Option {
 	}
 }
 
+// WithSSEPath is an Option to set the URL path for the SSE sessions of SSEConnector.
+func WithSSEPath(p string) Option {
+	return func(o *Options) {
+		o.SSEPath = p
+	}
+}
+
 // WithWriteTimeout is an Option to set the maximum time of one write message operation to complete.
 func WithWriteTimeout(d time.Duration) Option {
 	return func(o *Options) {
@@ -82,6 +298,114 @@ func WithWriteTimeout(d time.Duration) Option {
 	}
 }
 
+// WithAdaptiveWriteTimeout is an Option to raise the write timeout of each client to rttMultiplier times its measured RTT,
+// capped at maxTimeout, so that the clients on the high-latency mobile networks are not disconnected on a transient hiccup
+// while the fast clients still get the tight WriteTimeout, which is the floor.
+// A non-positive maxTimeout caps at 10 seconds, as the write timeout must always be bounded to detect slow consumers.
+// The RTT is measured by the pings, see WithPingInterval, only the pongs echoing the latest ping are counted.
+//
+// Note that a write timing out can not be retried, since the WebSocket connection is left corrupted
+// by the partially written frame, so the escalation happens through a longer deadline up front.
+func WithAdaptiveWriteTimeout(rttMultiplier float64, maxTimeout time.Duration) Option {
+	return func(o *Options) {
+		o.WriteTimeoutRTTMultiplier = rttMultiplier
+		o.MaxWriteTimeout = maxTimeout
+	}
+}
+
+// WithReadTimeout is an Option to set how long a client may stay silent before it is disconnected with ErrReadTimeout.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ReadTimeout = d
+	}
+}
+
+// WithPingInterval is an Option to set how often a ping is sent to each client to measure its round-trip time.
+func WithPingInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.PingInterval = d
+	}
+}
+
+// WithWriteCoalescing is an Option to coalesce the outgoing messages of each client and write them every interval,
+// or as soon as they add up to maxBytes, which reduces the per-message syscall overhead of high-frequency
+// small messages such as position updates, at the cost of up to interval of latency.
+// The coalesced messages are written as a single message separated by newlines, so the client must split them,
+// which makes it only suitable for the encodings without a raw newline in a message, such as JSON.
+// A maxBytes of 0 means no size limit.
+func WithWriteCoalescing(interval time.Duration, maxBytes int) Option {
+	return func(o *Options) {
+		o.WriteCoalesceInterval = interval
+		o.WriteCoalesceMaxBytes = maxBytes
+	}
+}
+
+// WithSlowConsumerPolicy is an Option to set what happens to a client once it is detected as a slow consumer.
+func WithSlowConsumerPolicy(p SlowConsumerPolicy) Option {
+	return func(o *Options) {
+		o.SlowConsumerPolicy = p
+	}
+}
+
+// WithOnSlowConsumer is an Option to set the hook invoked when a client is first detected as a slow consumer,
+// for the application-specific handling such as logging the user or lowering the update rate of the client.
+func WithOnSlowConsumer(hook SlowConsumerHook) Option {
+	return func(o *Options) {
+		o.OnSlowConsumer = hook
+	}
+}
+
+// WithOnConnect is an Option to set the hook invoked when a client is connected,
+// for the application to get the Client, such as to attach tags via Client.AddTag.
+func WithOnConnect(hook ClientHook) Option {
+	return func(o *Options) {
+		o.OnConnect = hook
+	}
+}
+
+// WithOnDisconnect is an Option to set the hook invoked after a client is closed, with the cause,
+// such as for cleaning up the application state of the client or counting the disconnects by cause.
+func WithOnDisconnect(hook DisconnectHook) Option {
+	return func(o *Options) {
+		o.OnDisconnect = hook
+	}
+}
+
+// WithOnMessage is an Option to set the handler invoked with every message read from a client,
+// on a single goroutine per client, so the messages of a client are handled in order.
+// A handler slower than the client sends stops the reads from the client once its read buffer is full, see WithReadBuffer.
+func WithOnMessage(handler MessageHandler) Option {
+	return func(o *Options) {
+		o.OnMessage = handler
+	}
+}
+
+// WithClientOptions is an Option to append ClientOptions applied to every client accepted by the connector.
+func WithClientOptions(opts ...ClientOption) Option {
+	return func(o *Options) {
+		o.ClientOptions = append(o.ClientOptions, opts...)
+	}
+}
+
+// WithOutboundInterceptors is an Option to append interceptors applied to the data written to every client,
+// they run in the order they are appended, each on the result of the previous one.
+func WithOutboundInterceptors(interceptors ...OutboundInterceptor) Option {
+	return func(o *Options) {
+		o.OutboundInterceptors = append(o.OutboundInterceptors, interceptors...)
+	}
+}
+
+// WithBandwidthQuota is an Option to limit the bytes per second read from each client to rate,
+// with bursts of up to burst bytes, and policy decides what happens to a client exceeding it.
+// Set it well above the bandwidth of the legit clients, as it is meant to stop the abusive ones.
+func WithBandwidthQuota(rate, burst int, policy BandwidthPolicy) Option {
+	return func(o *Options) {
+		o.BandwidthQuota = rate
+		o.BandwidthBurst = burst
+		o.BandwidthPolicy = policy
+	}
+}
+
 // WithMaxMessageSize is an Option to set maximum message size in bytes allowed from client.
 func WithMaxMessageSize(s int64) Option {
 	return func(o *Options) {
@@ -99,6 +423,59 @@ func WithTLSCertAndKey(certFile, keyFile string) Option {
 	}
 }
 
+// WithAutoTLS is an Option to obtain and renew the TLS certificates of domains automatically from Let's Encrypt,
+// so WebsocketConnector can expose wss:// directly without a reverse proxy.
+// The server must be reachable by domains on port 443, and on port 80 for the HTTP-01 challenge,
+// see WithAutoTLSHTTPAddr. By using this Option, you agree to the Let's Encrypt Terms of Service.
+func WithAutoTLS(domains ...string) Option {
+	return func(o *Options) {
+		o.AutoTLSDomains = domains
+	}
+}
+
+// WithAutoTLSCache is an Option to set where the certificates obtained by WithAutoTLS are stored.
+func WithAutoTLSCache(cache autocert.Cache) Option {
+	return func(o *Options) {
+		o.AutoTLSCache = cache
+	}
+}
+
+// WithAutoTLSHTTPAddr is an Option to set the TCP address to serve the ACME HTTP-01 challenge on,
+// an empty string disables it, which leaves only the TLS-ALPN-01 challenge on the TLS port.
+func WithAutoTLSHTTPAddr(addr string) Option {
+	return func(o *Options) {
+		o.AutoTLSHTTPAddr = addr
+	}
+}
+
+// WithClientCertAuth is an Option to request the client certificates verified with clientCAs by authType,
+// such as tls.RequireAndVerifyClientCert for server-to-server or trusted-device scenarios.
+// The verified certificates are available via Client.PeerCertificates.
+func WithClientCertAuth(clientCAs *x509.CertPool, authType tls.ClientAuthType) Option {
+	return func(o *Options) {
+		o.ClientCAs = clientCAs
+		o.ClientAuth = authType
+	}
+}
+
+// WithPayloadEncryption is an Option to encrypt every message with AES-256-GCM independently of TLS,
+// with the key agreed by an X25519 handshake that every client must perform right after connecting:
+// the client sends its 32 bytes public key as the first message and receives the server public key in reply,
+// and both derive a key per direction with HKDF-SHA256 from the shared secret, salted with the client public key
+// followed by the server public key, and the info "ppcserver payload encryption v2 client to server"
+// or "ppcserver payload encryption v2 server to client".
+// Every message afterwards is the sealed payload only, sent in binary frames, and its nonce is its sequence number
+// in its direction starting from 0, as 12 bytes in big-endian. A replayed, reordered, reflected or tampered message
+// fails the authentication and closes the connection.
+// privateKey is the static X25519 private key of the server for the clients to pin its public key,
+// or nil to generate an ephemeral key per connection.
+func WithPayloadEncryption(privateKey []byte) Option {
+	return func(o *Options) {
+		o.PayloadEncryption = true
+		o.EncryptionPrivateKey = privateKey
+	}
+}
+
 // WithHTTPServeMux is an Option to set a custom http.ServeMux,
 // will also update Server.Handler to mux if Options.Server is not nil.
 func WithHTTPServeMux(mux *http.ServeMux) Option {
@@ -125,9 +502,139 @@ func WithHTTPServer(s *http.Server) Option {
 	}
 }
 
-// WithWebsocketUpgrader is an Option to set a custom websocket.Upgrader.
+// WithWebsocketUpgrader is an Option to set a custom websocket.Upgrader,
+// such as one with EnableCompression set to negotiate the permessage-deflate compression with the clients.
 func WithWebsocketUpgrader(upgrader *websocket.Upgrader) Option {
 	return func(o *Options) {
 		o.Upgrader = upgrader
 	}
 }
+
+// WithProtocolVersions is an Option to set the protocol versions supported by the server, in the order of preference.
+func WithProtocolVersions(versions ...string) Option {
+	return func(o *Options) {
+		o.ProtocolVersions = versions
+	}
+}
+
+// WithBanList is an Option to set the banlist.Store for rejecting banned peers before accepting a connection.
+func WithBanList(s banlist.Store) Option {
+	return func(o *Options) {
+		o.BanList = s
+	}
+}
+
+// WithBanListFailClosed is an Option to set whether a connection is rejected when the banlist.Store fails.
+// By default the connectors fail open, trading the enforcement of the bans for the availability during an outage,
+// pass true to fail closed instead, such as for a game where letting a banned peer in is worse than an outage.
+func WithBanListFailClosed(failClosed bool) Option {
+	return func(o *Options) {
+		o.BanListFailClosed = failClosed
+	}
+}
+
+// WithAuditSink is an Option to set the audit.Sink receiving the audit events.
+// Wrap the banlist.Store with audit.NewAuditedStore to also audit the bans themselves.
+func WithAuditSink(sink audit.Sink) Option {
+	return func(o *Options) {
+		o.AuditSink = sink
+	}
+}
+
+// WithConnRateLimitPerIP is an Option to limit the connection attempts from a single IP address
+// to rate per second with bursts of at most burst attempts.
+// IPv6 addresses are limited per /64 prefix, as a single host usually holds a whole /64.
+// The attempts exceed the limit are rejected with 429 Too Many Requests.
+func WithConnRateLimitPerIP(rate float64, burst int) Option {
+	return func(o *Options) {
+		o.ConnRatePerIP = rate
+		o.ConnBurstPerIP = burst
+	}
+}
+
+// WithAcceptRateLimit is an Option to limit the connection attempts from all peers
+// to rate per second with bursts of at most burst attempts.
+// The attempts exceed the limit are rejected with 429 Too Many Requests.
+func WithAcceptRateLimit(rate float64, burst int) Option {
+	return func(o *Options) {
+		o.AcceptRate = rate
+		o.AcceptBurst = burst
+	}
+}
+
+// WithRateLimitsReload is an Option to set f to return the rate limits applied by the Reload of the connector,
+// which the ppcserver.Server invokes on SIGHUP, such as by re-reading a config file.
+// The rate limits in effect are kept if f returns an error.
+func WithRateLimitsReload(f RateLimitsFunc) Option {
+	return func(o *Options) {
+		o.ReloadRateLimits = f
+	}
+}
+
+// WithClock is an Option to set the Clock telling the current time to the rate limits,
+// such as a ppctest.Clock for the tests to advance the time without sleeping.
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithShutdownNotice is an Option to send a ShutdownNotice with the reconnect hint to every client on server shutdown.
+// See ShutdownNotice for how reconnectHost, delay and jitter are interpreted by the clients.
+func WithShutdownNotice(reconnectHost string, delay, jitter time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownNotice = NewShutdownNotice(reconnectHost, delay, jitter)
+	}
+}
+
+// WithClientVersionRange is an Option to set the range of the client build versions supported,
+// such as "1.4.0" and "" to require an update of the clients older than 1.4.0, an empty bound is not checked.
+// The clients send their versions on connecting, see ClientVersionParam and ClientVersionHeader.
+// A client out of the range, or not sending a version, is told to update with an Error of ErrorCodeUpdateRequired
+// before its connection is closed, see NumUpdateRequired and NumClientsByVersion for the metrics.
+func WithClientVersionRange(minVersion, maxVersion string) Option {
+	return func(o *Options) {
+		o.MinClientVersion = minVersion
+		o.MaxClientVersion = maxVersion
+	}
+}
+
+// WithFeatureFlags is an Option to set the feature flags for the handlers to consult via Client.FeatureFlags.
+// Every client receives a FeatureFlagsMessage with all the flags after connecting,
+// and then one with the changes whenever ff is updated, so the client can switch features live.
+func WithFeatureFlags(ff featureflag.FeatureFlags) Option {
+	return func(o *Options) {
+		o.FeatureFlags = ff
+	}
+}
+
+// WithSessionAnalytics is an Option to emit a SessionSummaryEvent to e when a client disconnects,
+// such as to an analytics.Exporter, for diagnosing the network issues of the regions and the client versions.
+// The event carries the duration, the disconnect reason, the bytes in and out, the dropped messages,
+// the RTT distribution sampled on every ping, and the reconnects reported by the client via ReconnectsParam.
+func WithSessionAnalytics(e analytics.Emitter) Option {
+	return func(o *Options) {
+		o.SessionAnalytics = e
+	}
+}
+
+// WithGeoIP is an Option to resolve the region of every client by r at connect time.
+// The region is exposed by Client.Region, tagged to the Client with RegionTagPrefix,
+// counted by NumClientsByRegion, and reported in the SessionSummaryEvent.
+func WithGeoIP(r GeoIPResolver) Option {
+	return func(o *Options) {
+		o.GeoIP = r
+	}
+}
+
+// WithRegionRedirect is an Option to redirect the clients of the other regions to the closer endpoints,
+// where localRegion is the region this server serves, and endpoints map the other regions to their hosts,
+// such as {"eu-west": "eu.example.com"}. It requires WithGeoIP to resolve the regions of the clients.
+// A client whose region has an endpoint receives a RedirectMessage and is closed before being served,
+// see NumRegionRedirects. The clients of localRegion, an unknown region, or a region without an endpoint are served.
+func WithRegionRedirect(localRegion string, endpoints map[string]string) Option {
+	return func(o *Options) {
+		o.LocalRegion = localRegion
+		o.RegionEndpoints = endpoints
+	}
+}
@@ -0,0 +1,25 @@
+package connector
+
+const (
+	// ProtocolVersion1 is the first version of the protocol between server and client.
+	// A client that does not request any protocol version is assumed to speak ProtocolVersion1,
+	// as it is deployed before the protocol version negotiation is introduced.
+	ProtocolVersion1 = "ppc.v1"
+)
+
+// negotiateProtocolVersion picks the protocol version for a client requesting the versions in requested,
+// by the preference order of supported, so a newer client downgrades gracefully to an older server.
+// ok is false if none of requested is supported.
+func negotiateProtocolVersion(supported, requested []string) (version string, ok bool) {
+	if len(requested) == 0 {
+		requested = []string{ProtocolVersion1}
+	}
+	for _, s := range supported {
+		for _, r := range requested {
+			if s == r {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
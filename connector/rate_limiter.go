@@ -0,0 +1,152 @@
+package connector
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ipLimiterSweepInterval is how often ipRateLimiter drops the buckets of the idle IPs.
+const ipLimiterSweepInterval = 1 * time.Minute
+
+type (
+	// tokenBucket allows bursts of up to burst events and refills at rate tokens per second.
+	// tokenBucket is not safe for concurrent use, the owner should guard it.
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+	}
+
+	// rateLimiter limits the rate of events with a single tokenBucket.
+	rateLimiter struct {
+		now    func() time.Time
+		mu     sync.Mutex // mu guards all the fields below.
+		rate   float64
+		burst  float64
+		bucket tokenBucket
+	}
+
+	// ipRateLimiter limits the rate of events per IP address with one tokenBucket per IP, or per /64 for IPv6.
+	ipRateLimiter struct {
+		now       func() time.Time
+		mu        sync.Mutex // mu guards all the fields below.
+		rate      float64
+		burst     float64
+		buckets   map[netip.Addr]*tokenBucket
+		lastSweep time.Time
+	}
+)
+
+// take refills the bucket for the time elapsed since the last call and then tries to take one token.
+func (b *tokenBucket) take(rate, burst float64, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full reports whether the bucket would have been refilled to burst at now.
+func (b *tokenBucket) full(rate, burst float64, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= burst
+}
+
+// normalizeBurst raises burst to 1 if less than 1, so that events can ever happen.
+func normalizeBurst(burst int) float64 {
+	if burst < 1 {
+		return 1
+	}
+	return float64(burst)
+}
+
+// newRateLimiter creates a rateLimiter telling the time by now, a rate less than or equal to 0 means no limit.
+func newRateLimiter(rate float64, burst int, now func() time.Time) *rateLimiter {
+	return &rateLimiter{now: now, rate: rate, burst: normalizeBurst(burst)}
+}
+
+// allow reports whether one more event may happen now.
+func (l *rateLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	return l.bucket.take(l.rate, l.burst, l.now())
+}
+
+// setLimit changes the limit at runtime, the tokens already in the bucket are kept.
+func (l *rateLimiter) setLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = normalizeBurst(burst)
+}
+
+// newIPRateLimiter creates an ipRateLimiter telling the time by now, a rate less than or equal to 0 means no limit.
+func newIPRateLimiter(rate float64, burst int, now func() time.Time) *ipRateLimiter {
+	return &ipRateLimiter{
+		now:     now,
+		rate:    rate,
+		burst:   normalizeBurst(burst),
+		buckets: make(map[netip.Addr]*tokenBucket),
+	}
+}
+
+// allow reports whether one more event from ip may happen now.
+// An IPv6 address shares the bucket of its /64 prefix, see ipRateLimitKey.
+func (l *ipRateLimiter) allow(ip netip.Addr) bool {
+	ip = ipRateLimitKey(ip)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+
+	// A bucket that has been refilled to burst behaves the same as a new one, so it is safe to drop.
+	// Sweep periodically to keep the map from growing with every IP ever seen.
+	if now.Sub(l.lastSweep) >= ipLimiterSweepInterval {
+		for addr, b := range l.buckets {
+			if b.full(l.rate, l.burst, now) {
+				delete(l.buckets, addr)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{}
+		l.buckets[ip] = b
+	}
+	return b.take(l.rate, l.burst, now)
+}
+
+// ipRateLimitKey returns the key of the bucket of ip, which is the /64 prefix of an IPv6 address,
+// since a single host is usually assigned a whole /64 and could otherwise rotate addresses to evade the limit.
+func ipRateLimitKey(ip netip.Addr) netip.Addr {
+	ip = ip.Unmap()
+	if !ip.Is6() {
+		return ip
+	}
+	return netip.PrefixFrom(ip.WithZone(""), 64).Masked().Addr()
+}
+
+// setLimit changes the limit of every IP at runtime, the tokens already in the buckets are kept.
+func (l *ipRateLimiter) setLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = normalizeBurst(burst)
+}
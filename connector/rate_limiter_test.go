@@ -0,0 +1,91 @@
+package connector_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	clock := ppctest.NewClock()
+	allow := connector.NewIPRateLimiter(1, 2, clock.Now)
+	a := netip.MustParseAddr("203.0.113.1")
+	b := netip.MustParseAddr("203.0.113.2")
+
+	for i := 0; i < 2; i++ {
+		if !allow(a) {
+			t.Fatalf("allow(a) #%d = false within the burst", i)
+		}
+	}
+	if allow(a) {
+		t.Fatal("allow(a) = true beyond the burst")
+	}
+	if !allow(b) {
+		t.Fatal("allow(b) = false, want a bucket of its own")
+	}
+
+	clock.Advance(time.Second)
+	if !allow(a) {
+		t.Fatal("allow(a) = false after refilled")
+	}
+	if allow(a) {
+		t.Fatal("allow(a) = true beyond the refilled token")
+	}
+}
+
+func TestIPRateLimiterIPv6Prefix(t *testing.T) {
+	allow := connector.NewIPRateLimiter(1, 1, ppctest.NewClock().Now)
+
+	if !allow(netip.MustParseAddr("2001:db8:0:1::1")) {
+		t.Fatal("allow() = false for the first address of the /64")
+	}
+	if allow(netip.MustParseAddr("2001:db8:0:1:ffff::2")) {
+		t.Fatal("allow() = true for another address of the same /64")
+	}
+	if !allow(netip.MustParseAddr("2001:db8:0:2::1")) {
+		t.Fatal("allow() = false for an address of another /64")
+	}
+}
+
+func TestIPRateLimiterMappedIPv4(t *testing.T) {
+	allow := connector.NewIPRateLimiter(1, 1, ppctest.NewClock().Now)
+
+	if !allow(netip.MustParseAddr("203.0.113.1")) {
+		t.Fatal("allow() = false for the IPv4 address")
+	}
+	if allow(netip.MustParseAddr("::ffff:203.0.113.1")) {
+		t.Fatal("allow() = true for the IPv4-mapped address of the same IPv4 address")
+	}
+}
+
+func TestConnRateLimitPerIP(t *testing.T) {
+	clock := ppctest.NewClock()
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithClock(clock),
+		connector.WithConnRateLimitPerIP(1, 2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		if got := dialStatus(t, s); got != http.StatusSwitchingProtocols {
+			t.Fatalf("status #%d = %d, want %d", i, got, http.StatusSwitchingProtocols)
+		}
+	}
+	if got := dialStatus(t, s); got != http.StatusTooManyRequests {
+		t.Fatalf("status beyond the burst = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := s.Connector.NumRateLimitedConns(); got != 1 {
+		t.Fatalf("NumRateLimitedConns() = %d, want 1", got)
+	}
+
+	clock.Advance(time.Second)
+	if got := dialStatus(t, s); got != http.StatusSwitchingProtocols {
+		t.Fatalf("status after refilled = %d, want %d", got, http.StatusSwitchingProtocols)
+	}
+}
@@ -0,0 +1,63 @@
+package connector
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+)
+
+type (
+	// RequestMetadata is the metadata of the HTTP request that opens a Client connection,
+	// such as the WebSocket upgrade request, for the hooks to read the campaign parameters,
+	// the client versions and the region hints sent by the peer.
+	// RequestMetadata must not be modified, as it is shared by everything reading the Client.
+	RequestMetadata struct {
+		Header     http.Header
+		Query      url.Values
+		Cookies    []*http.Cookie
+		RemoteAddr string
+		// PeerCertificates are the certificates presented by the peer over TLS, the first one is the leaf.
+		// It is nil if the request is not over TLS or the peer presents none.
+		PeerCertificates []*x509.Certificate
+	}
+
+	requestMetadataKey struct{}
+)
+
+// newRequestMetadata captures the metadata of r.
+func newRequestMetadata(r *http.Request) *RequestMetadata {
+	md := &RequestMetadata{
+		Header:     r.Header,
+		Query:      r.URL.Query(),
+		Cookies:    r.Cookies(),
+		RemoteAddr: r.RemoteAddr,
+	}
+	if r.TLS != nil {
+		md.PeerCertificates = r.TLS.PeerCertificates
+	}
+	return md
+}
+
+// Cookie returns the named cookie of the request, or nil if not found.
+func (md *RequestMetadata) Cookie(name string) *http.Cookie {
+	for _, cookie := range md.Cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// RequestMetadataFrom returns the RequestMetadata carried by ctx, such as the one returned by Client.Context,
+// or false if none.
+func RequestMetadataFrom(ctx context.Context) (*RequestMetadata, bool) {
+	md, ok := ctx.Value(requestMetadataKey{}).(*RequestMetadata)
+	return md, ok
+}
+
+// RequestMetadata returns the metadata of the request that opens the Client connection,
+// or nil if the Client is not started by a connector, see WithRequestMetadata.
+func (c *Client) RequestMetadata() *RequestMetadata {
+	return c.opts.RequestMetadata
+}
@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/analytics"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SessionSummaryEvent is the name of the analytics.Event emitted when a Client exits, see WithSessionAnalytics.
+	SessionSummaryEvent = "session_summary"
+	// ReconnectsParam is the query parameter for a client to send the number of times it has reconnected,
+	// which is reported as the "reconnects" property of SessionSummaryEvent.
+	ReconnectsParam = "reconnects"
+)
+
+// rttBucketsMs are the upper bounds in milliseconds of the RTT histogram buckets, the last bucket is unbounded.
+var rttBucketsMs = [...]int64{25, 50, 100, 200, 400, 800}
+
+// rttStats aggregates the RTT samples of a Client, it is only accessed by writeLoop until it exits.
+type rttStats struct {
+	count    int64
+	sum      time.Duration
+	min, max time.Duration
+	buckets  [len(rttBucketsMs) + 1]int64 // buckets[i] counts the samples <= rttBucketsMs[i], the last one the rest.
+}
+
+// add records an RTT sample.
+func (s *rttStats) add(rtt time.Duration) {
+	if s.count == 0 || rtt < s.min {
+		s.min = rtt
+	}
+	if rtt > s.max {
+		s.max = rtt
+	}
+	s.count++
+	s.sum += rtt
+
+	i := 0
+	for i < len(rttBucketsMs) && rtt.Milliseconds() > rttBucketsMs[i] {
+		i++
+	}
+	s.buckets[i]++
+}
+
+// properties returns the RTT distribution as the properties of SessionSummaryEvent, or nil if no sample.
+func (s *rttStats) properties() map[string]interface{} {
+	if s.count == 0 {
+		return nil
+	}
+	histogram := make(map[string]int64, len(s.buckets))
+	for i, n := range s.buckets {
+		le := "+Inf"
+		if i < len(rttBucketsMs) {
+			le = strconv.FormatInt(rttBucketsMs[i], 10)
+		}
+		histogram[le] = n
+	}
+	return map[string]interface{}{
+		"rtt_samples":      s.count,
+		"rtt_min_ms":       s.min.Milliseconds(),
+		"rtt_avg_ms":       (s.sum / time.Duration(s.count)).Milliseconds(),
+		"rtt_max_ms":       s.max.Milliseconds(),
+		"rtt_histogram_ms": histogram,
+	}
+}
+
+// DroppedMessages returns the number of messages dropped by Client.Write as the write queue is full.
+func (c *Client) DroppedMessages() int64 {
+	return atomic.LoadInt64(&c.droppedMessages)
+}
+
+// sampleRTT records the latest RTT measured by the transport, if any, into the RTT distribution.
+func (c *Client) sampleRTT() {
+	if rtt := c.RTT(); rtt > 0 {
+		c.rttStats.add(rtt)
+	}
+}
+
+// emitSessionSummary emits a SessionSummaryEvent of the Client to ClientOptions.SessionAnalytics, if any.
+// It must be called after writeLoop exits, as it reads the RTT distribution.
+func (c *Client) emitSessionSummary() {
+	if c.opts.SessionAnalytics == nil {
+		return
+	}
+
+	properties := map[string]interface{}{
+		"client_id":         c.id,
+		"duration_ms":       time.Since(c.connectedAt).Milliseconds(),
+		"disconnect_reason": c.DisconnectReason().String(),
+		"bytes_in":          c.BytesIn(),
+		"bytes_out":         c.BytesOut(),
+		"dropped_messages":  c.DroppedMessages(),
+		"protocol_version":  c.ProtocolVersion(),
+	}
+	if region := c.Region(); region != "" {
+		properties["region"] = region
+	}
+	if v := c.ClientVersion(); v != "" {
+		properties["client_version"] = v
+	}
+	if md := c.RequestMetadata(); md != nil {
+		if n, err := strconv.Atoi(md.Query.Get(ReconnectsParam)); err == nil && n >= 0 {
+			properties["reconnects"] = n
+		}
+	}
+	for k, v := range c.rttStats.properties() {
+		properties[k] = v
+	}
+	c.opts.SessionAnalytics.Emit(analytics.NewEvent(SessionSummaryEvent, properties))
+}
@@ -0,0 +1,96 @@
+package connector
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	// SlowConsumerPolicyKick closes a Client once it is detected as a slow consumer.
+	SlowConsumerPolicyKick SlowConsumerPolicy = iota
+	// SlowConsumerPolicyDrop keeps a slow consumer Client connected and drops the messages not fitting in its write queue.
+	SlowConsumerPolicyDrop
+)
+
+const (
+	// SlowConsumerReasonQueueFull means the write queue of the Client is full when writing a message.
+	SlowConsumerReasonQueueFull SlowConsumerReason = iota
+	// SlowConsumerReasonWriteTimeout means writing to the transport exceeds the write timeout.
+	SlowConsumerReasonWriteTimeout
+)
+
+var (
+	ErrWriteQueueFull = errors.New("ppcserver: client write queue is full")
+
+	numSlowConsumers int64
+)
+
+type (
+	// SlowConsumerPolicy decides what happens to a Client detected as a slow consumer.
+	SlowConsumerPolicy uint8
+
+	// SlowConsumerReason is why a Client is detected as a slow consumer.
+	SlowConsumerReason uint8
+
+	// SlowConsumerHook is invoked once per Client when it is first detected as a slow consumer,
+	// before SlowConsumerPolicy is applied.
+	SlowConsumerHook func(c *Client, reason SlowConsumerReason)
+)
+
+func (r SlowConsumerReason) String() string {
+	switch r {
+	case SlowConsumerReasonQueueFull:
+		return "write queue full"
+	case SlowConsumerReasonWriteTimeout:
+		return "write timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// NumSlowConsumers returns the number of Clients detected as slow consumers since the start.
+func NumSlowConsumers() int64 {
+	return atomic.LoadInt64(&numSlowConsumers)
+}
+
+// WriteQueueHighWaterMark returns the most messages ever waiting in the write queue of the Client.
+func (c *Client) WriteQueueHighWaterMark() int {
+	return int(atomic.LoadInt32(&c.writeQueueHighWaterMark))
+}
+
+// recordWriteQueueLen raises the high-water mark of the write queue to n if n is higher.
+func (c *Client) recordWriteQueueLen(n int) {
+	for {
+		hwm := atomic.LoadInt32(&c.writeQueueHighWaterMark)
+		if int32(n) <= hwm || atomic.CompareAndSwapInt32(&c.writeQueueHighWaterMark, hwm, int32(n)) {
+			return
+		}
+	}
+}
+
+// handleSlowConsumer counts the Client and invokes ClientOptions.OnSlowConsumer on the first detection,
+// and then applies ClientOptions.SlowConsumerPolicy.
+func (c *Client) handleSlowConsumer(reason SlowConsumerReason) {
+	if atomic.CompareAndSwapInt32(&c.slowConsumer, 0, 1) {
+		atomic.AddInt64(&numSlowConsumers, 1)
+		log.Printf("ppcserver: Client(%s) detected as slow consumer: %s", c.id, reason)
+		if c.opts.OnSlowConsumer != nil {
+			c.opts.OnSlowConsumer(c, reason)
+		}
+	}
+
+	if c.opts.SlowConsumerPolicy == SlowConsumerPolicyKick {
+		c.auditKick("slow consumer: " + reason.String())
+		// Cancel the Client-level context, StartClient will then close the Client.
+		c.setDisconnectReason(DisconnectReasonKicked)
+		c.cancelCtx()
+	}
+}
+
+// isTimeout reports whether err is caused by a timeout, such as an exceeded write deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,121 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+// startSlowConsumer starts a Client on a Transport whose peer never receives, with a write queue of a single message,
+// and writes n messages to it in OnConnect. It returns the Client, the errors of the writes, and the error of StartClient.
+func startSlowConsumer(
+	ctx context.Context, t *testing.T, n int, opt connector.ClientOption,
+) (*connector.Client, []error, <-chan error) {
+	t.Helper()
+	type connected struct {
+		c    *connector.Client
+		errs []error
+	}
+	connectedCh := make(chan connected, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(
+			ctx, ppctest.NewTransport(1), connector.WithWriteBuffer(1), opt,
+			func(o *connector.ClientOptions) {
+				o.OnConnect = func(c *connector.Client) {
+					// writeLoop is not started yet, so the messages back up in the write queue.
+					errs := make([]error, n)
+					for i := range errs {
+						errs[i] = c.Write([]byte("hello"))
+					}
+					connectedCh <- connected{c: c, errs: errs}
+				}
+			},
+		)
+	}()
+	select {
+	case got := <-connectedCh:
+		return got.c, got.errs, errCh
+	case err := <-errCh:
+		t.Fatalf("StartClient() = %v before connecting", err)
+	}
+	return nil, nil, nil
+}
+
+func TestSlowConsumerKick(t *testing.T) {
+	before := connector.NumSlowConsumers()
+	reasonCh := make(chan connector.SlowConsumerReason, 4)
+	c, errs, errCh := startSlowConsumer(
+		context.Background(), t, 3,
+		func(o *connector.ClientOptions) {
+			o.OnSlowConsumer = func(_ *connector.Client, reason connector.SlowConsumerReason) {
+				reasonCh <- reason
+			}
+		},
+	)
+
+	if errs[0] != nil {
+		t.Fatalf("Write() #0 = %v, want nil", errs[0])
+	}
+	for i, err := range errs[1:] {
+		if !errors.Is(err, connector.ErrWriteQueueFull) {
+			t.Fatalf("Write() #%d = %v, want %v", i+1, err, connector.ErrWriteQueueFull)
+		}
+	}
+	select {
+	case <-errCh:
+	case <-time.After(time.Second):
+		t.Fatal("the slow consumer is not kicked")
+	}
+
+	if got := c.DisconnectReason(); got != connector.DisconnectReasonKicked {
+		t.Fatalf("DisconnectReason() = %s, want %s", got, connector.DisconnectReasonKicked)
+	}
+	if reason := <-reasonCh; reason != connector.SlowConsumerReasonQueueFull {
+		t.Fatalf("OnSlowConsumer reason = %s, want %s", reason, connector.SlowConsumerReasonQueueFull)
+	}
+	if len(reasonCh) != 0 {
+		t.Fatalf("OnSlowConsumer is invoked %d more times, want once", len(reasonCh))
+	}
+	if got := connector.NumSlowConsumers() - before; got != 1 {
+		t.Fatalf("NumSlowConsumers() increased by %d, want 1", got)
+	}
+}
+
+func TestSlowConsumerDrop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, errs, errCh := startSlowConsumer(
+		ctx, t, 3,
+		func(o *connector.ClientOptions) {
+			o.SlowConsumerPolicy = connector.SlowConsumerPolicyDrop
+		},
+	)
+
+	for i, err := range errs[1:] {
+		if !errors.Is(err, connector.ErrWriteQueueFull) {
+			t.Fatalf("Write() #%d = %v, want %v", i+1, err, connector.ErrWriteQueueFull)
+		}
+	}
+	if got := c.DroppedMessages(); got != 2 {
+		t.Fatalf("DroppedMessages() = %d, want 2", got)
+	}
+	if got := c.WriteQueueHighWaterMark(); got != 1 {
+		t.Fatalf("WriteQueueHighWaterMark() = %d, want 1", got)
+	}
+
+	// The Client stays connected with the messages dropped.
+	select {
+	case err := <-errCh:
+		t.Fatalf("StartClient() = %v, want the slow consumer kept connected", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if got := c.State(); got == connector.ClientStateClosed {
+		t.Fatalf("State() = %v, want not closed", got)
+	}
+	cancel()
+	<-errCh
+}
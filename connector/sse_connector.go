@@ -0,0 +1,293 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var _ Connector = (*SSEConnector)(nil)
+
+type (
+	// SSEConnector accepts client connections over HTTP for the networks blocking WebSocket,
+	// with Server-Sent Events for the server to client messages and POST requests for the client to server messages.
+	//
+	// A client opens a session with GET Options.SSEPath, optionally with the protocol query parameters
+	// in the order of preference, such as "?protocol=ppc.v2&protocol=ppc.v1".
+	// The first event of the stream is a "session" event with the data {"session": id, "protocol": version},
+	// and every message afterward is an unnamed event, readable by EventSource.onmessage.
+	// The client sends a message with POST Options.SSEPath?session=id, the body is the message.
+	// Every POST is checked against Options.BanList as every GET is, but only the GETs count against the connection
+	// rate limits, since a POST carries a message of an open session instead of a connection attempt.
+	//
+	// Messages must be text, since SSE can not carry binary data, so Options.PayloadEncryption is not supported.
+	// TLS is served with Options.TLSCertFile and Options.TLSKeyFile, Options.AutoTLSDomains only applies to WebsocketConnector.
+	SSEConnector struct {
+		opts       *Options
+		clientsWg  sync.WaitGroup
+		setupOnce  sync.Once
+		gate       *connGate       // gate admits the sessions by the rate limits and the ban list.
+		clientOpts []ClientOption  // clientOpts are passed to StartClient for every session.
+		clients    *clientRegistry // clients are the Clients of the sessions of this SSEConnector, see Client and Clients.
+		sessionsMu sync.RWMutex
+		sessions   map[string]*sseTransport // sessions are the open sessions by ID, guarded by sessionsMu.
+	}
+
+	// sseConnKey is the context key of the net.Conn serving a request, see SSEConnector.Start.
+	sseConnKey struct{}
+
+	// sseSessionEvent is the data of the first event of a session stream.
+	sseSessionEvent struct {
+		Session  string `json:"session"`
+		Protocol string `json:"protocol"`
+	}
+)
+
+// NewSSEConnector creates a new SSEConnector.
+func NewSSEConnector(opts ...Option) *SSEConnector {
+	c := &SSEConnector{
+		opts:     defaultOptions(),
+		clients:  newClientRegistry(),
+		sessions: make(map[string]*sseTransport),
+	}
+
+	// Apply opts to customize SSEConnector.
+	for _, opt := range opts {
+		opt(c.opts)
+	}
+
+	c.gate = newConnGate(c.opts)
+	c.clientOpts = append(newClientOptions(c.opts), withClientRegistry(c.clients))
+
+	return c
+}
+
+// Start starts an HTTP server for serving the SSE sessions and block until the server is closed.
+// The sessions are closed when ctx is done, see WebsocketConnector.Start for the details.
+func (c *SSEConnector) Start(ctx context.Context) error {
+	// BaseContext makes the requests done when ctx is done, which also closes the streams of the sessions.
+	c.opts.Server.BaseContext = func(_ net.Listener) context.Context {
+		return ctx
+	}
+	// The handler and ConnContext are set up only once, since Start is invoked again when the Component is restarted.
+	c.setupOnce.Do(
+		func() {
+			// ConnContext exposes the connection of each request, for sseTransport to break a stalled stream.
+			connContext := c.opts.Server.ConnContext
+			c.opts.Server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+				if connContext != nil {
+					ctx = connContext(ctx, conn)
+				}
+				return context.WithValue(ctx, sseConnKey{}, conn)
+			}
+			c.handleSSE(ctx)
+		},
+	)
+
+	useTLS := c.opts.TLSCertFile != "" || c.opts.TLSKeyFile != ""
+	return serve(c.opts, useTLS)
+}
+
+// handleSSE registers the handler for the session streams and the posted messages at opts.SSEPath.
+func (c *SSEConnector) handleSSE(ctx context.Context) {
+	c.opts.ServeMux.HandleFunc(
+		c.opts.SSEPath, func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				c.serveStream(ctx, w, r)
+			case http.MethodPost:
+				c.servePost(w, r)
+			default:
+				w.Header().Set("Allow", "GET, POST")
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			}
+		},
+	)
+}
+
+// serveStream opens a session and streams the messages written to its Client until the session is closed.
+func (c *SSEConnector) serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if !c.gate.admit(w, r) {
+		return
+	}
+
+	requested := r.URL.Query()["protocol"]
+	version, ok := negotiateProtocolVersion(c.opts.ProtocolVersions, requested)
+	if !ok {
+		writeHTTPError(
+			w, http.StatusBadRequest, NewError(
+				ErrorCodeUnsupportedProtocol,
+				fmt.Sprintf("unsupported protocol versions, supported: %s", strings.Join(c.opts.ProtocolVersions, ", ")),
+				false,
+			),
+		)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("ppcserver: SSEConnector http.ResponseWriter does not support flushing")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	id, err := newSSESessionID()
+	if err != nil {
+		log.Println("ppcserver: SSEConnector.newSSESessionID() error:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable the response buffering of nginx.
+	w.WriteHeader(http.StatusOK)
+
+	conn, _ := r.Context().Value(sseConnKey{}).(net.Conn)
+	transport := newSSETransport(w, flusher, conn, c.opts.WriteTimeout)
+	data, _ := json.Marshal(sseSessionEvent{Session: id, Protocol: version})
+	if err := transport.writeEvent("session", data); err != nil {
+		log.Println("ppcserver: SSEConnector write session event error:", err)
+		return
+	}
+
+	c.sessionsMu.Lock()
+	c.sessions[id] = transport
+	c.sessionsMu.Unlock()
+	defer func() {
+		c.sessionsMu.Lock()
+		delete(c.sessions, id)
+		c.sessionsMu.Unlock()
+	}()
+
+	// Close the transport when the peer goes away, since there is no read on the stream to notice it.
+	// The request context is also done when ctx is done, in which case StartClient closes the Client
+	// after writing the shutdown message.
+	go func() {
+		select {
+		case <-r.Context().Done():
+			if ctx.Err() == nil {
+				_ = transport.Close()
+			}
+		case <-transport.closeCh:
+		}
+	}()
+
+	c.clientsWg.Add(1)
+	defer c.clientsWg.Done()
+
+	// Copy before append, as c.clientOpts is shared by all the sessions.
+	clientOpts := append(
+		c.clientOpts[:len(c.clientOpts):len(c.clientOpts)],
+		WithProtocolVersion(version),
+		WithClientVersion(clientVersion(r)),
+		WithRequestMetadata(newRequestMetadata(r)),
+		WithRegion(resolveRegion(c.opts, r)),
+	)
+	if err := StartClient(ctx, transport, clientOpts...); err != nil {
+		log.Println("ppcserver: StartClient() error:", err)
+	}
+	_ = transport.Close()
+}
+
+// servePost delivers the body of r as a message to the session named by the session query parameter.
+// It replies 204 No Content once the message is read by the Client, or 404 Not Found if the session is not open.
+// The posts are checked against the ban list as the streams are, so a banned peer can not post to a leaked session.
+func (c *SSEConnector) servePost(w http.ResponseWriter, r *http.Request) {
+	if !c.gate.admitPeer(w, r) {
+		return
+	}
+
+	c.sessionsMu.RLock()
+	transport, ok := c.sessions[r.URL.Query().Get("session")]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		writeHTTPError(w, http.StatusNotFound, errSessionNotFound)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if c.opts.MaxMessageSize > 0 {
+		// Read one byte beyond the limit to tell a message over the limit from one just at it.
+		body = io.LimitReader(r.Body, c.opts.MaxMessageSize+1)
+	}
+	message, err := io.ReadAll(body)
+	if err != nil {
+		// The peer went away or sent a malformed body, the reply is unlikely to reach it.
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if c.opts.MaxMessageSize > 0 && int64(len(message)) > c.opts.MaxMessageSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := transport.deliver(r.Context(), message); err != nil {
+		writeHTTPError(w, http.StatusNotFound, errSessionNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Shutdown stops the HTTP server and waits for all the sessions to be closed.
+func (c *SSEConnector) Shutdown(ctx context.Context) error {
+	if err := c.opts.Server.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// Wait for all the clients' Close complete.
+	c.clientsWg.Wait()
+	return nil
+}
+
+// Client returns the Client with id of the sessions of this SSEConnector,
+// or false if no such Client is started and not yet exited. See ClientByID for all the Clients on this node.
+func (c *SSEConnector) Client(id string) (*Client, bool) {
+	return c.clients.get(id)
+}
+
+// Clients returns a snapshot of the Clients of the sessions of this SSEConnector sorted by ID, see Clients for the details.
+func (c *SSEConnector) Clients() []*Client {
+	return c.clients.snapshot()
+}
+
+// NumRateLimitedConns returns the number of sessions rejected by the rate limits since the start.
+func (c *SSEConnector) NumRateLimitedConns() int64 {
+	return atomic.LoadInt64(&c.gate.numRateLimitedConns)
+}
+
+// Reload applies the rate limits returned by the RateLimitsFunc set via WithRateLimitsReload,
+// it satisfies ppcserver.Reloader, so the rate limits are reloaded on SIGHUP.
+func (c *SSEConnector) Reload(ctx context.Context) error {
+	return c.gate.reload(ctx)
+}
+
+// SetConnRateLimitPerIP changes the per-IP session limit at runtime, a rate <= 0 disables the limit.
+// See WithConnRateLimitPerIP for the details.
+func (c *SSEConnector) SetConnRateLimitPerIP(rate float64, burst int) {
+	c.gate.ipLimiter.setLimit(rate, burst)
+}
+
+// SetAcceptRateLimit changes the global session limit at runtime, a rate <= 0 disables the limit.
+// See WithAcceptRateLimit for the details.
+func (c *SSEConnector) SetAcceptRateLimit(rate float64, burst int) {
+	c.gate.acceptLimiter.setLimit(rate, burst)
+}
+
+// newSSESessionID returns a random session ID, which is unguessable as it authorizes posting to the session.
+func newSSESessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,176 @@
+package connector_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// sseEvent is an event read from an SSE stream.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSEEvent reads the next event from r, skipping the comment lines.
+func readSSEEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var e sseEvent
+	var data []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString() error = %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if e.name != "" || data != nil {
+				e.data = strings.Join(data, "\n")
+				return e
+			}
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+// openSSESession opens a session on s, and returns its stream positioned after the session event and its ID.
+// The caller must close the returned response body.
+func openSSESession(t *testing.T, s *ppctest.SSEServer) (*http.Response, *bufio.Reader, string) {
+	t.Helper()
+	resp, err := http.Get(s.URL + "/sse?protocol=" + url.QueryEscape(connector.ProtocolVersion1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		t.Fatalf("GET status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		_ = resp.Body.Close()
+		t.Fatalf("Content-Type = %q, want %q", got, "text/event-stream")
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	e := readSSEEvent(t, stream)
+	if e.name != "session" {
+		t.Fatalf("first event = %q, want %q", e.name, "session")
+	}
+	var session struct {
+		Session  string `json:"session"`
+		Protocol string `json:"protocol"`
+	}
+	if err := json.Unmarshal([]byte(e.data), &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Session == "" || session.Protocol != connector.ProtocolVersion1 {
+		t.Fatalf("session event = %s", e.data)
+	}
+	return resp, stream, session.Session
+}
+
+// postSSE posts message to session on s, and returns the HTTP status of the response.
+func postSSE(t *testing.T, s *ppctest.SSEServer, session, message string) int {
+	t.Helper()
+	resp, err := http.Post(s.URL+"/sse?session="+url.QueryEscape(session), "text/plain", strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSSEConnector(t *testing.T) {
+	s, err := ppctest.StartSSEServer(
+		connector.WithOnMessage(
+			func(c *connector.Client, message []byte) {
+				_ = c.Write(append([]byte("echo "), message...))
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	resp, stream, session := openSSESession(t, s)
+	defer resp.Body.Close()
+
+	if got := postSSE(t, s, session, "hello"); got != http.StatusNoContent {
+		t.Fatalf("POST status = %d, want %d", got, http.StatusNoContent)
+	}
+	if e := readSSEEvent(t, stream); e.name != "" || e.data != "echo hello" {
+		t.Fatalf("event = %+v, want the data %q", e, "echo hello")
+	}
+}
+
+func TestSSEConnectorPostNotRateLimited(t *testing.T) {
+	s, err := ppctest.StartSSEServer(
+		connector.WithClock(ppctest.NewClock()),
+		connector.WithConnRateLimitPerIP(1, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	resp, _, session := openSSESession(t, s)
+	defer resp.Body.Close()
+
+	// The session took the only token, while the messages of the session take none.
+	for i := 0; i < 5; i++ {
+		if got := postSSE(t, s, session, "hello"); got != http.StatusNoContent {
+			t.Fatalf("POST #%d status = %d, want %d", i, got, http.StatusNoContent)
+		}
+	}
+	if got := s.Connector.NumRateLimitedConns(); got != 0 {
+		t.Fatalf("NumRateLimitedConns() = %d, want 0", got)
+	}
+
+	again, err := http.Get(s.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = again.Body.Close()
+	if again.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("GET status beyond the burst = %d, want %d", again.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func TestSSEConnectorMessageTooLarge(t *testing.T) {
+	s, err := ppctest.StartSSEServer(connector.WithMaxMessageSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	resp, _, session := openSSESession(t, s)
+	defer resp.Body.Close()
+
+	if got := postSSE(t, s, session, "12345"); got != http.StatusRequestEntityTooLarge {
+		t.Fatalf("POST status over the limit = %d, want %d", got, http.StatusRequestEntityTooLarge)
+	}
+	if got := postSSE(t, s, session, "1234"); got != http.StatusNoContent {
+		t.Fatalf("POST status at the limit = %d, want %d", got, http.StatusNoContent)
+	}
+}
+
+func TestSSEConnectorUnknownSession(t *testing.T) {
+	s, err := ppctest.StartSSEServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := postSSE(t, s, "unknown", "hello"); got != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", got, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,182 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	TransportProtocolTypeSSE TransportProtocolType = "sse"
+)
+
+var (
+	errSSESessionClosed = errors.New("ppcserver: SSE session closed")
+
+	ssePing      = []byte(": ping\n\n")
+	sseDataField = []byte("data: ")
+)
+
+// sseTransport is a Transport over a Server-Sent Events stream for writing to the peer,
+// and the POST requests of the same session for reading from the peer, see SSEConnector.
+type sseTransport struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// conn is the network connection of the stream, closed when a write exceeds writeTimeout, nil if unknown.
+	conn         net.Conn
+	writeTimeout time.Duration
+	mu           sync.Mutex    // mu guards writing to w.
+	readCh       chan []byte   // readCh is unbuffered, so a POST request is not answered until its message is read.
+	closeCh      chan struct{} // closeCh is closed by Close, after which Read returns io.EOF and Write fails.
+	closeOnce    sync.Once
+}
+
+// newSSETransport creates an sseTransport streaming to w, a write exceeding writeTimeout closes conn, if not nil.
+func newSSETransport(w http.ResponseWriter, flusher http.Flusher, conn net.Conn, writeTimeout time.Duration) *sseTransport {
+	return &sseTransport{
+		w:            w,
+		flusher:      flusher,
+		conn:         conn,
+		writeTimeout: writeTimeout,
+		readCh:       make(chan []byte),
+		closeCh:      make(chan struct{}),
+	}
+}
+
+// ProtocolType returns the protocol type of the transport.
+func (t *sseTransport) ProtocolType() TransportProtocolType {
+	return TransportProtocolTypeSSE
+}
+
+// NetConn returns nil, since the session spans the stream and the POST requests on different connections.
+func (t *sseTransport) NetConn() net.Conn {
+	return nil
+}
+
+// Read returns the next message posted by the peer, or io.EOF once the transport is closed.
+func (t *sseTransport) Read() ([]byte, error) {
+	select {
+	case message := <-t.readCh:
+		return message, nil
+	case <-t.closeCh:
+		return nil, io.EOF
+	}
+}
+
+// deliver hands message posted by the peer to Read, and blocks until it is read, ctx is done, or the transport is closed.
+func (t *sseTransport) deliver(ctx context.Context, message []byte) error {
+	select {
+	case t.readCh <- message:
+		return nil
+	case <-t.closeCh:
+		return errSSESessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Write writes data as a single event, each line of data goes in its own data field,
+// which the EventSource of the peer joins back with newlines.
+// The lines are split at every line terminator of SSE, see sseLines.
+func (t *sseTransport) Write(data []byte) error {
+	var buf bytes.Buffer
+	for _, line := range sseLines(data) {
+		buf.Write(sseDataField)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return t.write(buf.Bytes())
+}
+
+// sseLines splits data at "\r\n", "\r" and "\n", which all end a line of an SSE stream,
+// so that a message containing a bare "\r" can not inject fields or events into the stream.
+// A "\r\n" or "\r" reaches the peer as "\n".
+func sseLines(data []byte) [][]byte {
+	var lines [][]byte
+	for {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			return append(lines, data)
+		}
+		lines = append(lines, data[:i])
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			i++
+		}
+		data = data[i+1:]
+	}
+}
+
+// writeEvent writes data as an event of the named type.
+func (t *sseTransport) writeEvent(event string, data []byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("event: ")
+	buf.WriteString(event)
+	buf.WriteByte('\n')
+	buf.Write(sseDataField)
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	return t.write(buf.Bytes())
+}
+
+// Ping writes a comment line, which keeps the idle stream open through the proxies.
+// The RTT is not measured, as EventSource does not reply to it.
+func (t *sseTransport) Ping() error {
+	return t.write(ssePing)
+}
+
+// RTT always returns 0, see Ping.
+func (t *sseTransport) RTT() time.Duration {
+	return 0
+}
+
+// write writes p to the stream and flushes it, within writeTimeout.
+func (t *sseTransport) write(p []byte) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Fail fast once closed, the stream handler is about to return and the ResponseWriter must not be used after that.
+	select {
+	case <-t.closeCh:
+		return net.ErrClosed
+	default:
+	}
+
+	// http.ResponseWriter has no write deadline, so a stalled stream is broken by closing its connection,
+	// which unblocks the write. With HTTP/2, this also closes the other streams multiplexed on the connection.
+	if t.conn != nil && t.writeTimeout > 0 {
+		timer := time.AfterFunc(
+			t.writeTimeout, func() {
+				_ = t.conn.Close()
+			},
+		)
+		defer func() {
+			if !timer.Stop() {
+				err = os.ErrDeadlineExceeded // A timeout error, so the Client is handled as a slow consumer.
+			}
+		}()
+	}
+
+	if _, err := t.w.Write(p); err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// Close closes the transport, which makes the stream handler return and end the response.
+// It is safe to call Close more than once.
+func (t *sseTransport) Close() error {
+	t.closeOnce.Do(
+		func() {
+			close(t.closeCh)
+		},
+	)
+	return nil
+}
@@ -0,0 +1,84 @@
+package connector_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stalledConn is a net.Conn whose Close unblocks the writes of stalledWriter.
+type stalledConn struct {
+	net.Conn
+	closeOnce sync.Once
+	closedCh  chan struct{}
+}
+
+func (c *stalledConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closedCh) })
+	return nil
+}
+
+// stalledWriter is an http.ResponseWriter whose writes block until its connection is closed, as a stalled stream.
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	conn *stalledConn
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	<-w.conn.closedCh
+	return 0, net.ErrClosed
+}
+
+func TestSSETransportWriteTimeout(t *testing.T) {
+	conn := &stalledConn{closedCh: make(chan struct{})}
+	w := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), conn: conn}
+	transport := connector.NewSSETransport(w, w, conn, 20*time.Millisecond)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- transport.Write([]byte("hello")) }()
+	select {
+	case err := <-errCh:
+		if !connector.IsTimeout(err) {
+			t.Fatalf("Write() error = %v, want a timeout error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write() on a stalled stream does not return")
+	}
+}
+
+func TestSSETransportWrite(t *testing.T) {
+	conn := &stalledConn{closedCh: make(chan struct{})}
+	w := httptest.NewRecorder()
+	transport := connector.NewSSETransport(w, w, conn, time.Second)
+
+	if err := transport.Write([]byte("a\nb")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Body.String(), "data: a\ndata: b\n\n"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+	select {
+	case <-conn.closedCh:
+		t.Fatal("the connection is closed after a timely write")
+	default:
+	}
+	var _ http.Flusher = w
+}
+
+func TestSSETransportWriteLineTerminators(t *testing.T) {
+	w := httptest.NewRecorder()
+	transport := connector.NewSSETransport(w, w, nil, time.Second)
+
+	// Every line terminator of SSE starts a new data field, so the message can not inject an event of its own.
+	if err := transport.Write([]byte("a\revent: evil\r\ndata: b\n\rc")); err != nil {
+		t.Fatal(err)
+	}
+	want := "data: a\ndata: event: evil\ndata: data: b\ndata: \ndata: c\n\n"
+	if got := w.Body.String(); got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package connector
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// TraceDirectionIn is a message read from the peer.
+	TraceDirectionIn TraceDirection = iota
+	// TraceDirectionOut is a message written to the peer.
+	TraceDirectionOut
+)
+
+type (
+	// TraceDirection tells whether a TraceRecord is read from or written to the peer.
+	TraceDirection uint8
+
+	// TraceRecord is a message recorded by the trace of a Client, see Client.StartTrace.
+	TraceRecord struct {
+		Time      time.Time
+		Direction TraceDirection
+		Data      []byte
+	}
+
+	// traceBuffer is a ring buffer of the latest TraceRecords.
+	traceBuffer struct {
+		mu      sync.Mutex    // mu guards the fields below.
+		records []TraceRecord // records has a fixed length, the oldest record is overwritten when full.
+		next    int           // next is the index that the next record goes to.
+		full    bool          // full is true once records have wrapped around.
+	}
+)
+
+func (d TraceDirection) String() string {
+	switch d {
+	case TraceDirectionIn:
+		return "in"
+	case TraceDirectionOut:
+		return "out"
+	default:
+		return "unknown"
+	}
+}
+
+// StartTrace starts recording the latest capacity messages read from and written to the Client with timestamps,
+// for debugging a specific player such as investigating a desync. The previous trace, if any, is discarded.
+// Tracing copies every message, so enable it only for the Clients under investigation.
+// StartTrace is safe for concurrent use, such as from an admin API handler.
+func (c *Client) StartTrace(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.trace.Store(&traceBuffer{records: make([]TraceRecord, capacity)})
+}
+
+// StopTrace stops recording and returns the recorded messages from the oldest, or nil if not tracing.
+func (c *Client) StopTrace() []TraceRecord {
+	b, _ := c.trace.Swap((*traceBuffer)(nil)).(*traceBuffer)
+	if b == nil {
+		return nil
+	}
+	return b.snapshot()
+}
+
+// Trace returns the messages recorded so far from the oldest while the recording goes on, or nil if not tracing.
+func (c *Client) Trace() []TraceRecord {
+	b, _ := c.trace.Load().(*traceBuffer)
+	if b == nil {
+		return nil
+	}
+	return b.snapshot()
+}
+
+// traceMessage records data in the trace of the Client if tracing.
+func (c *Client) traceMessage(direction TraceDirection, data []byte) {
+	b, _ := c.trace.Load().(*traceBuffer)
+	if b == nil {
+		return
+	}
+	b.add(TraceRecord{Time: time.Now(), Direction: direction, Data: append([]byte(nil), data...)})
+}
+
+func (b *traceBuffer) add(r TraceRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records[b.next] = r
+	b.next++
+	if b.next == len(b.records) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+func (b *traceBuffer) snapshot() []TraceRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]TraceRecord(nil), b.records[:b.next]...)
+	}
+	return append(append([]TraceRecord(nil), b.records[b.next:]...), b.records[:b.next]...)
+}
@@ -1,17 +1,24 @@
 package connector
 
-import "net"
+import (
+	"net"
+	"time"
+)
+
+// batchSeparator separates the messages coalesced into a single message.
+var batchSeparator = []byte{'\n'}
 
 type (
 	// TransportProtocolType describes the protocol type name of the connection transport between server and client,
-	// currently only supports "websocket".
+	// such as TransportProtocolTypeWebsocket and TransportProtocolTypeSSE.
 	TransportProtocolType string
 
 	// Transport abstracts a connection transport between server and client.
 	Transport interface {
 		// ProtocolType should return the protocol type of the transport.
 		ProtocolType() TransportProtocolType
-		// NetConn should return the internal net.Conn of the connection.
+		// NetConn should return the internal net.Conn of the connection,
+		// or nil if the transport has no single connection, such as SSE, whose session spans several requests.
 		NetConn() net.Conn
 		//
 		Read() ([]byte, error)
@@ -20,4 +27,20 @@ type (
 		// Close should close the underlying network connection.
 		Close() error
 	}
+
+	// Pinger is an optional interface for a Transport to support the heartbeat and the round-trip time measurement.
+	Pinger interface {
+		// Ping should send a ping to the peer, and update RTT when the matching pong is received.
+		// Ping must be safe to call concurrently with Write.
+		Ping() error
+		// RTT should return the latest measured round-trip time with the peer, or 0 if not measured yet.
+		RTT() time.Duration
+	}
+
+	// BatchWriter is an optional interface for a Transport to write multiple messages as a single message
+	// without joining them into a new buffer first.
+	BatchWriter interface {
+		// WriteBatch should write messages as a single message, with each message separated by a newline.
+		WriteBatch(messages [][]byte) error
+	}
 )
@@ -2,23 +2,41 @@ package connector
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/gorilla/websocket"
 	"log"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// encryptionHandshakeTimeout is the maximum time for a client to complete the handshake of WithPayloadEncryption.
+const encryptionHandshakeTimeout = 10 * time.Second
+
+var _ Connector = (*WebsocketConnector)(nil)
+
 // WebsocketConnector accepts WebSocket client connections,
 // responsible for sending and receiving data with a WebSocket client.
 type WebsocketConnector struct {
-	opts      *Options
-	clientsWg sync.WaitGroup
+	opts       *Options
+	clientsWg  sync.WaitGroup
+	setupOnce  sync.Once
+	gate       *connGate       // gate admits the connection attempts by the rate limits and the ban list.
+	clientOpts []ClientOption  // clientOpts are passed to StartClient for every accepted connection.
+	clients    *clientRegistry // clients are the Clients accepted by this WebsocketConnector, see Client and Clients.
+	// challengeServer serves the ACME HTTP-01 challenge, it is nil unless both AutoTLSDomains and AutoTLSHTTPAddr are set.
+	challengeServer *http.Server
 }
 
 // NewWebsocketConnector creates a new WebsocketConnector.
 func NewWebsocketConnector(opts ...Option) *WebsocketConnector {
 	c := &WebsocketConnector{
-		opts: defaultOptions(),
+		opts:    defaultOptions(),
+		clients: newClientRegistry(),
 	}
 
 	// Apply opts to customize WebsocketConnector.
@@ -26,6 +44,31 @@ func NewWebsocketConnector(opts ...Option) *WebsocketConnector {
 		opt(c.opts)
 	}
 
+	if len(c.opts.AutoTLSDomains) > 0 {
+		manager := newAutoTLSManager(c.opts)
+		c.opts.Server.TLSConfig = manager.TLSConfig()
+		if c.opts.AutoTLSHTTPAddr != "" {
+			c.challengeServer = &http.Server{
+				Addr:    c.opts.AutoTLSHTTPAddr,
+				Handler: manager.HTTPHandler(nil),
+			}
+		}
+	}
+
+	if c.opts.ClientAuth != tls.NoClientCert {
+		// Clone to not mutate a tls.Config shared with others, such as the one set via WithHTTPServer.
+		tlsConfig := &tls.Config{}
+		if c.opts.Server.TLSConfig != nil {
+			tlsConfig = c.opts.Server.TLSConfig.Clone()
+		}
+		tlsConfig.ClientCAs = c.opts.ClientCAs
+		tlsConfig.ClientAuth = c.opts.ClientAuth
+		c.opts.Server.TLSConfig = tlsConfig
+	}
+
+	c.gate = newConnGate(c.opts)
+	c.clientOpts = append(newClientOptions(c.opts), withClientRegistry(c.clients))
+
 	return c
 }
 
@@ -40,72 +83,124 @@ func (c *WebsocketConnector) Start(ctx context.Context) error {
 		return ctx
 	}
 
-	// HandleFunc registers the handler for processing WebSocket connection requests at opts.WebsocketPath.
+	// The WebSocket handler is set up only once, since Start is invoked again
+	// with the same ctx when the Component is restarted, and http.ServeMux panics on registering a path twice.
+	c.setupOnce.Do(
+		func() {
+			c.handleWebsocket(ctx)
+		},
+	)
+
+	// The ACME challenge server lives as long as Start, so its failure to listen fails Start,
+	// and the FailurePolicy of the Component applies to it as well.
+	if c.challengeServer != nil {
+		stop, err := startACMEChallenge(c.challengeServer)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	// With WithAutoTLS, TLSCertFile and TLSKeyFile are empty and the certificates come from Server.TLSConfig.
+	useTLS := c.opts.TLSCertFile != "" || c.opts.TLSKeyFile != "" || len(c.opts.AutoTLSDomains) > 0
+	return serve(c.opts, useTLS)
+}
+
+// handleWebsocket registers the handler for processing WebSocket connection requests at opts.WebsocketPath.
+func (c *WebsocketConnector) handleWebsocket(ctx context.Context) {
 	c.opts.ServeMux.HandleFunc(
 		c.opts.WebsocketPath, func(w http.ResponseWriter, r *http.Request) {
+			if !c.gate.admit(w, r) {
+				return
+			}
+
+			// Negotiate the protocol version before upgrading, so an unsupported client gets a readable HTTP error.
+			var responseHeader http.Header
+			if c.opts.Upgrader.Subprotocols == nil {
+				requested := websocket.Subprotocols(r)
+				version, ok := negotiateProtocolVersion(c.opts.ProtocolVersions, requested)
+				if !ok {
+					writeHTTPError(
+						w, http.StatusBadRequest, NewError(
+							ErrorCodeUnsupportedProtocol,
+							fmt.Sprintf("unsupported protocol versions, supported: %s", strings.Join(c.opts.ProtocolVersions, ", ")),
+							false,
+						),
+					)
+					return
+				}
+				// The Sec-WebSocket-Protocol response header must only be set when the client requests any.
+				if len(requested) > 0 {
+					responseHeader = http.Header{"Sec-Websocket-Protocol": {version}}
+				}
+			}
+
 			// Note: upgrader.Upgrade will reply to the client with an HTTP error when it returns an error.
-			conn, err := c.opts.Upgrader.Upgrade(w, r, nil)
+			conn, err := c.opts.Upgrader.Upgrade(w, r, responseHeader)
 			if err != nil {
 				log.Println("ppcserver: WebsocketConnector.upgrader.Upgrade() error:", err)
 				return
 			}
 			defer conn.Close() // Ensure the connection is closed when the current function exits.
 
+			// An empty subprotocol means the client does not request any, see ProtocolVersion1.
+			version := conn.Subprotocol()
+			if version == "" {
+				version = ProtocolVersion1
+			}
+			// Copy before append, as c.clientOpts is shared by all the connections.
+			clientOpts := append(
+				c.clientOpts[:len(c.clientOpts):len(c.clientOpts)],
+				WithProtocolVersion(version),
+				WithClientVersion(clientVersion(r)),
+				WithRequestMetadata(newRequestMetadata(r)),
+				WithRegion(resolveRegion(c.opts, r)),
+			)
+
 			c.clientsWg.Add(1)
 			defer c.clientsWg.Done()
 
-			// go func() {
-			// 	time.Sleep(time.Second * 3)
-			//
-			// 	if err := conn.WriteControl(
-			// 		websocket.CloseMessage, websocket.FormatCloseMessage(3000, "hello"),
-			// 		time.Now().Add(3*time.Second),
-			// 	); err != nil {
-			// 		log.Println("ppcserver: WriteControl() error:", err)
-			// 	}
-			// }()
-
 			// SetReadLimit will close the connection when a client sends bytes larger than MaxMessageSize
 			// and returns ErrReadLimit from Client.transport.Read().
 			if c.opts.MaxMessageSize > 0 {
 				conn.SetReadLimit(c.opts.MaxMessageSize)
 			}
 
-			// TODO, wait pong
-			// conn.SetReadDeadline(time.Now().Add(0))
-			// c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+			wt := newWebsocketTransport(
+				conn,
+				EncodingTypeJSON, // TODO, encodingType depends
+				c.opts,
+			)
+			var transport Transport = wt
+			if c.opts.PayloadEncryption {
+				// Bound the handshake, so a peer not sending its public key does not occupy the connection forever.
+				wt.readTimeout = encryptionHandshakeTimeout
+				et, err := newEncryptedTransport(transport, c.opts.EncryptionPrivateKey)
+				if err != nil {
+					log.Println("ppcserver: WebsocketConnector encryption handshake error:", err)
+					return
+				}
+				wt.readTimeout = c.opts.ReadTimeout
+				transport = et
+			}
 
 			if err := StartClient(
 				// Note: ctx passes in for closing the connection gracefully when the server is shutting down.
-				ctx, newWebsocketTransport(
-					conn,
-					EncodingTypeJSON, // TODO, encodingType depends
-					c.opts,
-				),
+				ctx, transport,
+				clientOpts...,
 			); err != nil {
 				log.Println("ppcserver: StartClient() error:", err)
 			}
 		},
 	)
-
-	// ListenAndServe will block until the server is closed for various reasons,
-	// such as when WebsocketConnector.Shutdown() is invoked,
-	// or when PORT is already in-used.
-	var err error
-	if c.opts.TLSCertFile != "" || c.opts.TLSKeyFile != "" {
-		err = c.opts.Server.ListenAndServeTLS(c.opts.TLSCertFile, c.opts.TLSKeyFile)
-	} else {
-		err = c.opts.Server.ListenAndServe()
-	}
-	// ErrServerClosed returns on calling http.Server.Shutdown() and does not mean ListenAndServe() fails,
-	// so we return a nil error; for the other errors we return as is.
-	if err == http.ErrServerClosed {
-		return nil
-	}
-	return err
 }
 
 func (c *WebsocketConnector) Shutdown(ctx context.Context) error {
+	if c.challengeServer != nil {
+		if err := c.challengeServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	if err := c.opts.Server.Shutdown(ctx); err != nil {
 		return err
 	}
@@ -114,3 +209,37 @@ func (c *WebsocketConnector) Shutdown(ctx context.Context) error {
 	c.clientsWg.Wait()
 	return nil
 }
+
+// Client returns the Client with id accepted by this WebsocketConnector,
+// or false if no such Client is started and not yet exited. See ClientByID for all the Clients on this node.
+func (c *WebsocketConnector) Client(id string) (*Client, bool) {
+	return c.clients.get(id)
+}
+
+// Clients returns a snapshot of the Clients accepted by this WebsocketConnector sorted by ID, see Clients for the details.
+func (c *WebsocketConnector) Clients() []*Client {
+	return c.clients.snapshot()
+}
+
+// NumRateLimitedConns returns the number of connection attempts rejected by the rate limits since the start.
+func (c *WebsocketConnector) NumRateLimitedConns() int64 {
+	return atomic.LoadInt64(&c.gate.numRateLimitedConns)
+}
+
+// Reload applies the rate limits returned by the RateLimitsFunc set via WithRateLimitsReload,
+// it satisfies ppcserver.Reloader, so the rate limits are reloaded on SIGHUP.
+func (c *WebsocketConnector) Reload(ctx context.Context) error {
+	return c.gate.reload(ctx)
+}
+
+// SetConnRateLimitPerIP changes the per-IP connection attempt limit at runtime, a rate <= 0 disables the limit.
+// See WithConnRateLimitPerIP for the details.
+func (c *WebsocketConnector) SetConnRateLimitPerIP(rate float64, burst int) {
+	c.gate.ipLimiter.setLimit(rate, burst)
+}
+
+// SetAcceptRateLimit changes the global connection attempt limit at runtime, a rate <= 0 disables the limit.
+// See WithAcceptRateLimit for the details.
+func (c *WebsocketConnector) SetAcceptRateLimit(rate float64, burst int) {
+	c.gate.acceptLimiter.setLimit(rate, burst)
+}
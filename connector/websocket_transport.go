@@ -1,30 +1,51 @@
 package connector
 
 import (
+	"crypto/rand"
 	"github.com/gorilla/websocket"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	TransportProtocolTypeWebsocket TransportProtocolType = "websocket"
+
+	// defaultMaxWriteTimeout caps the adaptive write timeout if Options.MaxWriteTimeout is not positive,
+	// so a peer with a huge RTT can not disable the write timeout.
+	defaultMaxWriteTimeout = 10 * time.Second
+	// pingTokenSize is the size of the random payload of a ping, which the peer must echo back in the pong.
+	pingTokenSize = 8
 )
 
 // websocketTransport is a wrapper struct over websocket connection to fit Transport
 // interface so Client will accept it.
 type websocketTransport struct {
+	rtt int64 // rtt is the latest round-trip time in nanoseconds, accessed atomically and kept first for 64-bit alignment.
+
 	conn     *websocket.Conn
 	encoding EncodingType
 	opts     *Options
+
+	pingMu     sync.Mutex // pingMu guards pingToken and pingSentAt.
+	pingToken  string     // pingToken is the payload of the ping awaiting its pong, or empty if none.
+	pingSentAt time.Time
+	// readTimeout is how long Read waits for any frame from the peer, it is only accessed by the reading goroutine.
+	readTimeout time.Duration
 }
 
 func newWebsocketTransport(conn *websocket.Conn, encoding EncodingType, opts *Options) *websocketTransport {
 	transport := &websocketTransport{
-		conn:     conn,
-		encoding: encoding,
-		opts:     opts,
+		conn:        conn,
+		encoding:    encoding,
+		opts:        opts,
+		readTimeout: opts.ReadTimeout,
 	}
 
+	// The pong handler is invoked from within Read, so it runs on the reading goroutine.
+	conn.SetPongHandler(transport.handlePong)
+
 	return transport
 }
 
@@ -38,27 +59,141 @@ func (t *websocketTransport) NetConn() net.Conn {
 	return t.conn.UnderlyingConn()
 }
 
+// Read reads the next message from the peer, it returns ErrReadTimeout if no frame, including pong,
+// arrives within readTimeout.
 func (t *websocketTransport) Read() ([]byte, error) {
+	t.extendReadDeadline()
 	_, message, err := t.conn.ReadMessage()
+	if err != nil && isTimeout(err) {
+		return nil, ErrReadTimeout
+	}
 	return message, err
 }
 
+// extendReadDeadline pushes the read deadline readTimeout away from now, or clears it if readTimeout is not positive.
+// It must be called from the reading goroutine.
+func (t *websocketTransport) extendReadDeadline() {
+	var deadline time.Time
+	if t.readTimeout > 0 {
+		deadline = time.Now().Add(t.readTimeout)
+	}
+	_ = t.conn.SetReadDeadline(deadline)
+}
+
 // Write data to websocket.Conn.
 func (t *websocketTransport) Write(data []byte) error {
-	messageType := websocket.TextMessage
-	if t.encoding == EncodingTypeProtobuf {
-		messageType = websocket.BinaryMessage
+	t.setWriteDeadline()
+
+	if err := t.conn.WriteMessage(t.messageType(), data); err != nil {
+		return err
 	}
 
-	// SetWriteDeadline should be set per WriteMessage call.
-	if t.opts.WriteTimeout > 0 {
-		_ = t.conn.SetWriteDeadline(time.Now().Add(t.opts.WriteTimeout))
+	return nil
+}
+
+// WriteBatch writes messages into a single websocket message through NextWriter,
+// with each message separated by a newline, so that they go out with a single frame.
+func (t *websocketTransport) WriteBatch(messages [][]byte) error {
+	t.setWriteDeadline()
+
+	w, err := t.conn.NextWriter(t.messageType())
+	if err != nil {
+		return err
 	}
+	for i, m := range messages {
+		if i > 0 {
+			if _, err := w.Write(batchSeparator); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(m); err != nil {
+			return err
+		}
+	}
+	// Close flushes the complete message to the network.
+	return w.Close()
+}
 
-	if err := t.conn.WriteMessage(messageType, data); err != nil {
+// messageType returns the websocket message type by the encoding and whether the payload is encrypted.
+func (t *websocketTransport) messageType() int {
+	// The encrypted messages are not valid UTF-8, which is required by text frames.
+	if t.encoding == EncodingTypeProtobuf || t.opts.PayloadEncryption {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// setWriteDeadline should be called per write operation.
+func (t *websocketTransport) setWriteDeadline() {
+	if d := t.writeTimeout(); d > 0 {
+		_ = t.conn.SetWriteDeadline(time.Now().Add(d))
+	}
+}
+
+// writeTimeout returns Options.WriteTimeout, raised to Options.WriteTimeoutRTTMultiplier times the measured RTT
+// if the adaptive write timeout is enabled via WithAdaptiveWriteTimeout.
+// The raised timeout is always capped, at Options.MaxWriteTimeout or defaultMaxWriteTimeout if it is not positive,
+// but never below Options.WriteTimeout.
+func (t *websocketTransport) writeTimeout() time.Duration {
+	d := t.opts.WriteTimeout
+	if d <= 0 || t.opts.WriteTimeoutRTTMultiplier <= 0 {
+		return d
+	}
+	maxTimeout := t.opts.MaxWriteTimeout
+	if maxTimeout <= 0 {
+		maxTimeout = defaultMaxWriteTimeout
+	}
+	adaptive := time.Duration(float64(t.RTT()) * t.opts.WriteTimeoutRTTMultiplier)
+	if adaptive > maxTimeout || adaptive < 0 { // A negative duration means the multiplication overflows.
+		adaptive = maxTimeout
+	}
+	if adaptive > d {
+		d = adaptive
+	}
+	return d
+}
+
+// Ping sends a ping carrying a random token, which the peer echoes back in the pong to measure the RTT.
+// Only the pong of the latest ping is accepted, see handlePong.
+// Ping uses WriteControl, so it is safe to call concurrently with Write.
+func (t *websocketTransport) Ping() error {
+	payload := make([]byte, pingTokenSize)
+	if _, err := rand.Read(payload); err != nil {
 		return err
 	}
+	t.pingMu.Lock()
+	t.pingToken, t.pingSentAt = string(payload), time.Now()
+	t.pingMu.Unlock()
+
+	var deadline time.Time
+	if d := t.writeTimeout(); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	return t.conn.WriteControl(websocket.PingMessage, payload, deadline)
+}
+
+// RTT returns the round-trip time measured by the latest pong, or 0 if no pong is received yet.
+func (t *websocketTransport) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.rtt))
+}
+
+// handlePong extends the read deadline as the peer is alive, and updates the RTT
+// if the pong echoes the token of the ping awaiting its pong, which is then no longer awaited.
+// Unsolicited pongs, repeated pongs, or pongs with a payload not sent by the latest Ping do not update the RTT,
+// so the peer can not forge its RTT, the RTT is measured by the server clock only.
+func (t *websocketTransport) handlePong(appData string) error {
+	t.extendReadDeadline()
+
+	t.pingMu.Lock()
+	if t.pingToken == "" || appData != t.pingToken {
+		t.pingMu.Unlock()
+		return nil
+	}
+	rtt := time.Since(t.pingSentAt)
+	t.pingToken = ""
+	t.pingMu.Unlock()
 
+	atomic.StoreInt64(&t.rtt, int64(rtt))
 	return nil
 }
 
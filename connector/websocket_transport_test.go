@@ -0,0 +1,71 @@
+package connector_test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+// measureRTT connects a peer answering every ping with pong(payload), and returns the RTT measured by the server.
+func measureRTT(t *testing.T, pong func(payload string) string) time.Duration {
+	t.Helper()
+	clientCh := make(chan *connector.Client, 1)
+	s, err := ppctest.StartWebsocketServer(
+		connector.WithPingInterval(10*time.Millisecond),
+		connector.WithOnConnect(func(c *connector.Client) { clientCh <- c }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pingCh := make(chan struct{}, 16)
+	conn.SetPingHandler(
+		func(payload string) error {
+			select {
+			case pingCh <- struct{}{}:
+			default:
+			}
+			return conn.WriteControl(websocket.PongMessage, []byte(pong(payload)), time.Now().Add(time.Second))
+		},
+	)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := <-clientCh
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pingCh:
+		case <-time.After(time.Second):
+			t.Fatal("no ping received")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return c.RTT()
+}
+
+func TestWebsocketTransportMeasuresRTTFromEchoedPong(t *testing.T) {
+	if rtt := measureRTT(t, func(payload string) string { return payload }); rtt <= 0 || rtt > time.Second {
+		t.Fatalf("RTT() = %v, want a small positive RTT", rtt)
+	}
+}
+
+func TestWebsocketTransportIgnoresForgedPong(t *testing.T) {
+	// A forged payload claiming the ping was sent long ago must not inflate the RTT.
+	forged := func(string) string { return "\x00\x00\x00\x00\x00\x00\x00\x01" }
+	if rtt := measureRTT(t, forged); rtt != 0 {
+		t.Fatalf("RTT() = %v, want 0 for the forged pongs", rtt)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pom-pom-crafts/ppcserver/connector"
 	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
@@ -14,6 +15,12 @@ func main() {
 			http.ServeFile(w, r, "client.html")
 		},
 	)
+	// Serve the JavaScript client used by client.html.
+	http.HandleFunc(
+		"/ppcclient.js", func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, "../../clients/js/ppcclient.js")
+		},
+	)
 	log.Println("Open client.html through: http://localhost:8080")
 
 	ppcserver.NewServer(
@@ -21,6 +28,8 @@ func main() {
 			connector.NewWebsocketConnector(
 				connector.WithAddr(":8080"),
 				connector.WithWebsocketPath("/ws"),
+				// Ask the clients to reconnect after 1 to 5 seconds when the server shuts down.
+				connector.WithShutdownNotice("", 1*time.Second, 4*time.Second),
 			),
 		),
 	).Start()
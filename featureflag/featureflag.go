@@ -0,0 +1,45 @@
+// Package featureflag provides the feature flags consulted by the handlers for live ops experimentation,
+// with an in-memory implementation and an adapter polling a remote flag provider.
+package featureflag
+
+import "strconv"
+
+type (
+	// Change is a change of a flag, passed to the WatchFuncs.
+	Change struct {
+		Name  string
+		Value string
+		// Deleted is true if the flag is deleted, in which case Value is empty.
+		Deleted bool
+	}
+
+	// WatchFunc is invoked with the changes of the flags sorted by name, see FeatureFlags.Watch.
+	// changes are shared by all the WatchFuncs and must not be modified.
+	WatchFunc func(changes []Change)
+
+	// FeatureFlags abstracts the source of the feature flags, the values are strings
+	// that the handlers parse as needed, such as by Bool.
+	// Implementations must be safe for concurrent use.
+	FeatureFlags interface {
+		// Lookup should return the value of the flag name, or false if it does not exist.
+		Lookup(name string) (string, bool)
+		// All should return a snapshot of all the flags.
+		All() map[string]string
+		// Watch should invoke f with the changes after every update of the flags, in the order the updates are applied,
+		// until stop is called. f must not block, as it is invoked on the path updating the flags.
+		Watch(f WatchFunc) (stop func())
+	}
+)
+
+// Bool returns the flag name of ff parsed by strconv.ParseBool, or def if it does not exist or is not a bool.
+func Bool(ff FeatureFlags, name string, def bool) bool {
+	value, ok := ff.Lookup(name)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+package featureflag
+
+import (
+	"sort"
+	"sync"
+)
+
+var _ FeatureFlags = (*MemoryFlags)(nil)
+
+// MemoryFlags is an in-memory FeatureFlags set by the application, such as from an admin API or a config file.
+type MemoryFlags struct {
+	// updateMu serializes the updates together with their notifications,
+	// so that the watchers are notified of the changes in the order they are applied.
+	updateMu sync.Mutex
+	mu       sync.RWMutex         // mu guards flags, watchers and nextID.
+	flags    map[string]string    // flags are the current values by name.
+	watchers map[uint64]WatchFunc // watchers are the WatchFuncs subscribed via Watch by ID.
+	nextID   uint64
+}
+
+// NewMemoryFlags creates a new MemoryFlags with a copy of flags as the initial values.
+func NewMemoryFlags(flags map[string]string) *MemoryFlags {
+	f := &MemoryFlags{
+		flags:    make(map[string]string, len(flags)),
+		watchers: make(map[uint64]WatchFunc),
+	}
+	for name, value := range flags {
+		f.flags[name] = value
+	}
+	return f
+}
+
+func (f *MemoryFlags) Lookup(name string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	value, ok := f.flags[name]
+	return value, ok
+}
+
+func (f *MemoryFlags) All() map[string]string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	snapshot := make(map[string]string, len(f.flags))
+	for name, value := range f.flags {
+		snapshot[name] = value
+	}
+	return snapshot
+}
+
+func (f *MemoryFlags) Watch(fn WatchFunc) (stop func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID
+	f.nextID++
+	f.watchers[id] = fn
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.watchers, id)
+	}
+}
+
+// Set sets the flag name to value, the watchers are notified if the value changes.
+func (f *MemoryFlags) Set(name, value string) {
+	f.Update(map[string]string{name: value}, nil)
+}
+
+// Delete deletes the flag name, the watchers are notified if it exists.
+func (f *MemoryFlags) Delete(name string) {
+	f.Update(nil, []string{name})
+}
+
+// Replace replaces all the flags with a copy of flags, the watchers are notified of the differences.
+func (f *MemoryFlags) Replace(flags map[string]string) {
+	f.updateMu.Lock()
+	defer f.updateMu.Unlock()
+
+	f.mu.RLock()
+	var deleted []string
+	for name := range f.flags {
+		if _, ok := flags[name]; !ok {
+			deleted = append(deleted, name)
+		}
+	}
+	f.mu.RUnlock()
+	f.update(flags, deleted)
+}
+
+// Update sets the flags in set and deletes the ones in deleted at once,
+// the watchers are notified of the actual changes in the order of the names, if any.
+// Concurrent updates are applied one by one, each notified before the next is applied,
+// so a WatchFunc must not update f, or it deadlocks.
+func (f *MemoryFlags) Update(set map[string]string, deleted []string) {
+	f.updateMu.Lock()
+	defer f.updateMu.Unlock()
+	f.update(set, deleted)
+}
+
+// update applies and notifies an update, f.updateMu must be held.
+func (f *MemoryFlags) update(set map[string]string, deleted []string) {
+	f.mu.Lock()
+	var changes []Change
+	for name, value := range set {
+		if old, ok := f.flags[name]; ok && old == value {
+			continue
+		}
+		f.flags[name] = value
+		changes = append(changes, Change{Name: name, Value: value})
+	}
+	for _, name := range deleted {
+		if _, ok := f.flags[name]; !ok {
+			continue
+		}
+		delete(f.flags, name)
+		changes = append(changes, Change{Name: name, Deleted: true})
+	}
+	watchers := make([]WatchFunc, 0, len(f.watchers))
+	for _, fn := range f.watchers {
+		watchers = append(watchers, fn)
+	}
+	f.mu.Unlock()
+
+	if len(changes) == 0 {
+		return
+	}
+	sort.Slice(
+		changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		},
+	)
+	// Notify without holding mu, so a WatchFunc can read the flags or stop watching.
+	for _, fn := range watchers {
+		fn(changes)
+	}
+}
@@ -0,0 +1,92 @@
+package featureflag_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/featureflag"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryFlagsWatch(t *testing.T) {
+	f := featureflag.NewMemoryFlags(map[string]string{"a": "1", "b": "1"})
+	var got [][]featureflag.Change
+	stop := f.Watch(
+		func(changes []featureflag.Change) {
+			got = append(got, changes)
+		},
+	)
+
+	f.Set("a", "1") // Unchanged, not notified.
+	f.Set("a", "2")
+	f.Replace(map[string]string{"a": "2", "c": "3"})
+	stop()
+	f.Delete("a")
+
+	want := [][]featureflag.Change{
+		{{Name: "a", Value: "2"}},
+		{{Name: "b", Deleted: true}, {Name: "c", Value: "3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes = %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoryFlagsConcurrentUpdates(t *testing.T) {
+	const (
+		numWriters = 8
+		numUpdates = 100
+	)
+	f := featureflag.NewMemoryFlags(nil)
+
+	// The watcher mirrors the flags from the changes, as a connected client does.
+	// Every other notification is delayed to let the next update overtake it if the notifications were not ordered.
+	var mu sync.Mutex
+	var numNotified int32
+	mirror := make(map[string]string)
+	f.Watch(
+		func(changes []featureflag.Change) {
+			if atomic.AddInt32(&numNotified, 1)%2 == 0 {
+				time.Sleep(100 * time.Microsecond)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, change := range changes {
+				if change.Deleted {
+					delete(mirror, change.Name)
+				} else {
+					mirror[change.Name] = change.Value
+				}
+			}
+		},
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numUpdates; i++ {
+				value := strconv.Itoa(w*numUpdates + i)
+				switch i % 3 {
+				case 0:
+					f.Set("flag", value)
+				case 1:
+					f.Delete("flag")
+				default:
+					f.Replace(map[string]string{"flag": value, "other": value})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := f.All(); !reflect.DeepEqual(mirror, want) {
+		t.Fatalf("flags seen by the watcher = %v, want %v", mirror, want)
+	}
+}
@@ -0,0 +1,165 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+var _ FeatureFlags = (*RemoteFlags)(nil)
+
+// defaultHTTPClient is used by the Provider of NewHTTPProvider without a client,
+// its timeout keeps an unresponsive flag provider from stalling the polling forever.
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type (
+	// Provider fetches all the flags from a remote flag provider, such as a flag service or a config store.
+	// Implementations must be safe for concurrent use.
+	Provider interface {
+		Fetch(ctx context.Context) (map[string]string, error)
+	}
+
+	// ProviderFunc is an adapter to use a function as a Provider.
+	ProviderFunc func(ctx context.Context) (map[string]string, error)
+
+	// RemoteOption is a function to apply various configurations to customize a RemoteFlags.
+	RemoteOption func(f *RemoteFlags)
+
+	// RemoteFlags is a FeatureFlags and a Component that polls a Provider for the flags,
+	// and notifies the watchers of the differences from the last successful fetch.
+	// The flags are empty until the first fetch succeeds, register RemoteFlags before the Components consulting it,
+	// as it fetches once in Init.
+	RemoteFlags struct {
+		flags        *MemoryFlags // flags hold the last successful fetch.
+		provider     Provider
+		pollInterval time.Duration
+	}
+
+	// httpProvider fetches the flags as a JSON object of strings from a URL.
+	httpProvider struct {
+		url    string
+		client *http.Client
+	}
+)
+
+// Fetch calls f(ctx).
+func (f ProviderFunc) Fetch(ctx context.Context) (map[string]string, error) {
+	return f(ctx)
+}
+
+// NewHTTPProvider creates a Provider that GETs url with client, and decodes the response body
+// as a JSON object of strings, such as {"new_matchmaker": "true"}.
+// A client with a 10 seconds timeout is used if client is nil.
+func NewHTTPProvider(url string, client *http.Client) Provider {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	return &httpProvider{url: url, client: client}
+}
+
+func (p *httpProvider) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ppcserver: featureflag provider responds %s", resp.Status)
+	}
+	var flags map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// NewRemoteFlags creates a new RemoteFlags polling provider.
+func NewRemoteFlags(provider Provider, opts ...RemoteOption) *RemoteFlags {
+	f := &RemoteFlags{
+		flags:        NewMemoryFlags(nil),
+		provider:     provider,
+		pollInterval: defaultPollInterval,
+	}
+
+	// Apply opts to customize RemoteFlags.
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+func (f *RemoteFlags) Lookup(name string) (string, bool) {
+	return f.flags.Lookup(name)
+}
+
+func (f *RemoteFlags) All() map[string]string {
+	return f.flags.All()
+}
+
+func (f *RemoteFlags) Watch(fn WatchFunc) (stop func()) {
+	return f.flags.Watch(fn)
+}
+
+// Init fetches the flags once, so they are ready before the Components consulting them start.
+// A failed fetch is only logged, as the handlers fall back to their defaults, such as the def of Bool.
+func (f *RemoteFlags) Init(ctx context.Context) error {
+	if err := f.poll(ctx); err != nil {
+		log.Println("ppcserver: featureflag Provider.Fetch() error:", err)
+	}
+	return nil
+}
+
+// Start polls the Provider every poll interval and blocks until ctx is done.
+// A failed fetch is only logged, and the flags stay as the last successful fetch.
+func (f *RemoteFlags) Start(ctx context.Context) error {
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f.poll(ctx); err != nil && ctx.Err() == nil {
+				log.Println("ppcserver: featureflag Provider.Fetch() error:", err)
+			}
+		}
+	}
+}
+
+// Shutdown does nothing, as Start stops polling once its ctx is done.
+func (f *RemoteFlags) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// poll fetches the flags from the Provider and replaces the current ones.
+func (f *RemoteFlags) poll(ctx context.Context) error {
+	flags, err := f.provider.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	f.flags.Replace(flags)
+	return nil
+}
+
+// WithPollInterval is a RemoteOption to set the interval of polling the Provider, default is 30 seconds.
+// A d <= 0 applies the default.
+func WithPollInterval(d time.Duration) RemoteOption {
+	return func(f *RemoteFlags) {
+		if d <= 0 {
+			d = defaultPollInterval
+		}
+		f.pollInterval = d
+	}
+}
@@ -4,5 +4,11 @@ go 1.18
 
 require (
 	github.com/gorilla/websocket v1.5.0
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/crypto v0.24.0
+	golang.org/x/sync v0.7.0
+)
+
+require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 )
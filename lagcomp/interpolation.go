@@ -0,0 +1,21 @@
+package lagcomp
+
+import "time"
+
+// Lerp linearly interpolates between from and to, alpha 0 returns from and alpha 1 returns to.
+func Lerp(from, to, alpha float64) float64 {
+	return from + (to-from)*alpha
+}
+
+// TickAt returns the fractional tick that was current latency ago, given currentTick and a tick per tickInterval.
+// It is meant to be passed to SnapshotBuffer.Interpolate, and returns 0 if latency goes back further than tick 0.
+func TickAt(currentTick uint64, latency, tickInterval time.Duration) float64 {
+	if tickInterval <= 0 {
+		return float64(currentTick)
+	}
+	tick := float64(currentTick) - float64(latency)/float64(tickInterval)
+	if tick < 0 {
+		return 0
+	}
+	return tick
+}
@@ -0,0 +1,128 @@
+// Package lagcomp provides lag compensation utilities for authoritative game loops,
+// such as rewinding the world to the tick a client saw when it fired, before validating the hit.
+package lagcomp
+
+import (
+	"math"
+	"time"
+)
+
+type (
+	// SnapshotBuffer is a ring buffer of the recent world snapshots keyed by tick.
+	// It keeps the snapshots of the latest Capacity ticks, the older ones are overwritten.
+	// SnapshotBuffer is not safe for concurrent use, it is meant to be owned by the goroutine running the game loop.
+	SnapshotBuffer[S any] struct {
+		entries    []entry[S]
+		latestTick uint64
+		empty      bool
+	}
+
+	entry[S any] struct {
+		tick     uint64
+		snapshot S
+		valid    bool
+	}
+)
+
+// NewSnapshotBuffer creates a SnapshotBuffer keeping the snapshots of the latest capacity ticks.
+// capacity is raised to 1 if less than 1.
+func NewSnapshotBuffer[S any](capacity int) *SnapshotBuffer[S] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SnapshotBuffer[S]{
+		entries: make([]entry[S], capacity),
+		empty:   true,
+	}
+}
+
+// Capacity returns the number of ticks the SnapshotBuffer keeps.
+func (b *SnapshotBuffer[S]) Capacity() int {
+	return len(b.entries)
+}
+
+// Push stores the snapshot of tick, overwriting the snapshot stored Capacity ticks ago.
+// Pushing a tick older than the oldest tick kept is ignored.
+func (b *SnapshotBuffer[S]) Push(tick uint64, snapshot S) {
+	if !b.empty && tick+uint64(len(b.entries)) <= b.latestTick {
+		return
+	}
+	b.entries[tick%uint64(len(b.entries))] = entry[S]{tick: tick, snapshot: snapshot, valid: true}
+	if b.empty || tick > b.latestTick {
+		b.latestTick = tick
+		b.empty = false
+	}
+}
+
+// At returns the snapshot of tick, ok is false if tick is not kept.
+func (b *SnapshotBuffer[S]) At(tick uint64) (snapshot S, ok bool) {
+	e := b.entries[tick%uint64(len(b.entries))]
+	if !e.valid || e.tick != tick {
+		return snapshot, false
+	}
+	return e.snapshot, true
+}
+
+// Latest returns the latest tick and its snapshot, ok is false if nothing is pushed yet.
+func (b *SnapshotBuffer[S]) Latest() (tick uint64, snapshot S, ok bool) {
+	if b.empty {
+		return 0, snapshot, false
+	}
+	snapshot, ok = b.At(b.latestTick)
+	return b.latestTick, snapshot, ok
+}
+
+// oldestTick returns the oldest tick that may still be kept.
+func (b *SnapshotBuffer[S]) oldestTick() uint64 {
+	if n := uint64(len(b.entries)); b.latestTick >= n {
+		return b.latestTick - n + 1
+	}
+	return 0
+}
+
+// Rewind returns the snapshot of the tick a client with latency saw, given the game loop runs a tick per tickInterval.
+// The target tick is rounded to the nearest, and clamped to the oldest kept tick so that
+// a client with huge latency can not rewind further than the buffer allows, a negative latency is treated as 0.
+// ok is false if the clamped target tick is not kept, such as when ticks were skipped.
+func (b *SnapshotBuffer[S]) Rewind(latency, tickInterval time.Duration) (tick uint64, snapshot S, ok bool) {
+	if b.empty || tickInterval <= 0 {
+		return 0, snapshot, false
+	}
+
+	// A negative latency, such as from a skewed client clock, rewinds nothing.
+	if latency < 0 {
+		latency = 0
+	}
+	// Compare as a float before converting, as a huge latency may not fit in a uint64.
+	tick = b.oldestTick()
+	if back := math.Round(float64(latency) / float64(tickInterval)); back < float64(b.latestTick-tick) {
+		tick = b.latestTick - uint64(back)
+	}
+	snapshot, ok = b.At(tick)
+	return tick, snapshot, ok
+}
+
+// Interpolate returns the snapshot at a fractional tick, such as the render time a client reported,
+// by interpolating the two kept snapshots around it with lerp.
+// ok is false if either of the two snapshots is not kept.
+func (b *SnapshotBuffer[S]) Interpolate(tick float64, lerp func(from, to S, alpha float64) S) (snapshot S, ok bool) {
+	if tick < 0 {
+		return snapshot, false
+	}
+
+	fromTick := uint64(math.Floor(tick))
+	from, ok := b.At(fromTick)
+	if !ok {
+		return snapshot, false
+	}
+	alpha := tick - float64(fromTick)
+	if alpha == 0 {
+		return from, true
+	}
+
+	to, ok := b.At(fromTick + 1)
+	if !ok {
+		return snapshot, false
+	}
+	return lerp(from, to, alpha), true
+}
@@ -0,0 +1,39 @@
+package lagcomp_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/lagcomp"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSnapshotBufferRewind(t *testing.T) {
+	const tickInterval = 50 * time.Millisecond
+	b := lagcomp.NewSnapshotBuffer[uint64](8)
+	for tick := uint64(1); tick <= 20; tick++ {
+		b.Push(tick, tick*10)
+	}
+
+	tests := []struct {
+		name    string
+		latency time.Duration
+		want    uint64
+	}{
+		{"no latency", 0, 20},
+		{"rounded to the nearest tick", 120 * time.Millisecond, 18},
+		{"clamped to the oldest kept tick", 10 * time.Second, 13},
+		{"huge latency", math.MaxInt64, 13},
+		{"negative latency", -120 * time.Millisecond, 20},
+		{"most negative latency", math.MinInt64, 20},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				tick, snapshot, ok := b.Rewind(tt.latency, tickInterval)
+				if !ok || tick != tt.want || snapshot != tt.want*10 {
+					t.Fatalf("Rewind(%s) = %d, %d, %t, want %d, %d, true", tt.latency, tick, snapshot, ok, tt.want, tt.want*10)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,70 @@
+package playerstate
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store, the state is lost when the process exits.
+// It suits the tests and the single-node games with a snapshot of the state saved elsewhere.
+// The versions come from a counter shared by all the keys, so a deleted key does not keep a tombstone
+// to continue its versions from.
+type MemoryStore struct {
+	mu          sync.RWMutex     // mu guards the fields below.
+	entries     map[string]Entry // entries hold the copies of the values written, so callers can not modify them.
+	lastVersion uint64           // lastVersion is the version of the latest write to any key.
+}
+
+// NewMemoryStore creates a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return Entry{Value: clone(entry.Value), Version: entry.Version}, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(key, value), nil
+}
+
+func (s *MemoryStore) CompareAndSwap(_ context.Context, key string, value []byte, version uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A missing key has version 0 since the zero Entry is returned.
+	if s.entries[key].Version != version {
+		return 0, ErrVersionMismatch
+	}
+	return s.put(key, value), nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// put writes a copy of value to key with the next version, s.mu must be held.
+func (s *MemoryStore) put(key string, value []byte) uint64 {
+	s.lastVersion++
+	s.entries[key] = Entry{Value: clone(value), Version: s.lastVersion}
+	return s.lastVersion
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
@@ -0,0 +1,161 @@
+package playerstate_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/playerstate"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStoreCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	s := playerstate.NewMemoryStore()
+
+	v1, err := s.CompareAndSwap(ctx, "inventory", []byte("a"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompareAndSwap(ctx, "inventory", []byte("b"), 0); !errors.Is(err, playerstate.ErrVersionMismatch) {
+		t.Fatalf("CompareAndSwap() on an existing key with version 0 error = %v, want ErrVersionMismatch", err)
+	}
+	v2, err := s.CompareAndSwap(ctx, "inventory", []byte("b"), v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2 <= v1 {
+		t.Fatalf("version = %d after %d, want it to increase", v2, v1)
+	}
+	if _, err := s.CompareAndSwap(ctx, "inventory", []byte("c"), v1); !errors.Is(err, playerstate.ErrVersionMismatch) {
+		t.Fatalf("CompareAndSwap() with a stale version error = %v, want ErrVersionMismatch", err)
+	}
+
+	entry, err := s.Get(ctx, "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(entry.Value) != "b" || entry.Version != v2 {
+		t.Fatalf("Get() = %q@%d, want b@%d", entry.Value, entry.Version, v2)
+	}
+}
+
+func TestMemoryStoreDeleteDoesNotReuseVersions(t *testing.T) {
+	ctx := context.Background()
+	s := playerstate.NewMemoryStore()
+
+	stale, err := s.Set(ctx, "progress", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "progress"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "progress"); !errors.Is(err, playerstate.ErrNotFound) {
+		t.Fatalf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	v, err := s.Set(ctx, "progress", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v <= stale {
+		t.Fatalf("version after Delete() = %d, want greater than %d", v, stale)
+	}
+
+	// A writer holding the version read before the Delete must not overwrite the new value.
+	if _, err := s.CompareAndSwap(ctx, "progress", []byte("stale"), stale); !errors.Is(err, playerstate.ErrVersionMismatch) {
+		t.Fatalf("CompareAndSwap() with a version from before Delete() error = %v, want ErrVersionMismatch", err)
+	}
+}
+
+func TestMemoryStoreCompareAndSwapRace(t *testing.T) {
+	ctx := context.Background()
+	s := playerstate.NewMemoryStore()
+	v, err := s.Set(ctx, "coins", []byte("0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 32
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		won int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.CompareAndSwap(ctx, "coins", []byte("1"), v); err == nil {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if won != 1 {
+		t.Fatalf("%d CompareAndSwap() succeed with the same version, want 1", won)
+	}
+}
+
+func TestUpdateRace(t *testing.T) {
+	ctx := context.Background()
+	s := playerstate.NewMemoryStore()
+
+	const n = 32
+	var wg sync.WaitGroup
+	errCh := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := playerstate.Update(
+				ctx, s, "coins", 1000, func(value []byte, exists bool) ([]byte, error) {
+					coins := 0
+					if exists {
+						var err error
+						if coins, err = strconv.Atoi(string(value)); err != nil {
+							return nil, err
+						}
+					}
+					return []byte(strconv.Itoa(coins + 1)), nil
+				},
+			)
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, err := s.Get(ctx, "coins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(entry.Value); got != strconv.Itoa(n) {
+		t.Fatalf("coins = %s after %d concurrent increments, want %d", got, n, n)
+	}
+}
+
+func TestUpdateAbortsOnError(t *testing.T) {
+	ctx := context.Background()
+	s := playerstate.NewMemoryStore()
+	errAbort := errors.New("abort")
+
+	_, err := playerstate.Update(
+		ctx, s, "coins", 3, func(_ []byte, _ bool) ([]byte, error) {
+			return nil, errAbort
+		},
+	)
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update() error = %v, want the error of fn", err)
+	}
+	if _, err := s.Get(ctx, "coins"); !errors.Is(err, playerstate.ErrNotFound) {
+		t.Fatalf("Get() after an aborted Update() error = %v, want ErrNotFound", err)
+	}
+}
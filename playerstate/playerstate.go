@@ -0,0 +1,74 @@
+// Package playerstate provides a versioned key-value storage for small persistent player state,
+// such as inventories and progress, with compare-and-swap for the optimistic concurrency control.
+package playerstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound        = errors.New("ppcserver: playerstate key not found")
+	ErrVersionMismatch = errors.New("ppcserver: playerstate version mismatch")
+)
+
+type (
+	// Entry is a value stored under a key with its version.
+	// The version increases on every write and is never reused for a key, even after the key is deleted,
+	// so a version read before a Delete never matches again. 0 is never a version of a stored Entry.
+	Entry struct {
+		Value   []byte
+		Version uint64
+	}
+
+	// Store abstracts the storage of the player state, so that it can be backed by in-memory maps
+	// or by a shared remote storage such as Redis or NATS KV.
+	// Implementations must be safe for concurrent use, and must not retain or modify the value slices passed in.
+	Store interface {
+		// Get should return the Entry of key, or ErrNotFound if key does not exist.
+		Get(ctx context.Context, key string) (Entry, error)
+		// Set should write value to key unconditionally, and return the new version.
+		Set(ctx context.Context, key string, value []byte) (uint64, error)
+		// CompareAndSwap should write value to key only if the current version of key is version,
+		// where version 0 means key must not exist, and return the new version,
+		// or ErrVersionMismatch if the current version is not version.
+		CompareAndSwap(ctx context.Context, key string, value []byte, version uint64) (uint64, error)
+		// Delete should delete key, it is not an error if key does not exist.
+		// The versions of key keep increasing if it is written again, they must not restart.
+		Delete(ctx context.Context, key string) error
+	}
+
+	// UpdateFunc returns the new value of an Entry from its current value, exists is false if the key does not exist.
+	// It may be invoked more than once by Update, so it must not have side effects.
+	UpdateFunc func(value []byte, exists bool) ([]byte, error)
+)
+
+// Update applies fn to the Entry of key with CompareAndSwap, and retries from a fresh Get on ErrVersionMismatch,
+// up to maxRetries times, before giving up with ErrVersionMismatch. It returns the new version.
+// An error from fn aborts Update and is returned as is.
+func Update(ctx context.Context, s Store, key string, maxRetries int, fn UpdateFunc) (uint64, error) {
+	for attempt := 0; ; attempt++ {
+		entry, err := s.Get(ctx, key)
+		exists := err == nil
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return 0, fmt.Errorf("ppcserver: playerstate Store.Get() error: %w", err)
+		}
+
+		value, err := fn(entry.Value, exists)
+		if err != nil {
+			return 0, err
+		}
+
+		version, err := s.CompareAndSwap(ctx, key, value, entry.Version)
+		if err == nil {
+			return version, nil
+		}
+		if !errors.Is(err, ErrVersionMismatch) || attempt >= maxRetries {
+			return 0, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
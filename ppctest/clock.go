@@ -0,0 +1,33 @@
+package ppctest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock whose time only moves by Advance, for testing the time-dependent behaviors without sleeping,
+// such as the rate limits of a connector set via connector.WithClock.
+// Clock is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a Clock starting at 2022-01-01 00:00:00 UTC.
+func NewClock() *Clock {
+	return &Clock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the current time of the Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the time of the Clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,25 @@
+package ppctest_test
+
+import (
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	c := ppctest.NewClock()
+	start := c.Now()
+	if !start.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Now() = %s, want 2022-01-01 00:00:00 UTC", start)
+	}
+
+	time.Sleep(time.Millisecond)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("Now() = %s after sleeping, want %s", got, start)
+	}
+
+	c.Advance(90 * time.Second)
+	if got := c.Now().Sub(start); got != 90*time.Second {
+		t.Fatalf("Now() moved %s, want %s", got, 90*time.Second)
+	}
+}
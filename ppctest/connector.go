@@ -0,0 +1,100 @@
+package ppctest
+
+import (
+	"context"
+	"fmt"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"net"
+	"net/http"
+)
+
+type (
+	// WebsocketServer is a connector.WebsocketConnector listening on an ephemeral port of the loopback interface.
+	WebsocketServer struct {
+		// URL is the base WebSocket URL without the WebsocketPath, such as "ws://127.0.0.1:54321".
+		URL       string
+		Connector *connector.WebsocketConnector
+		runner
+	}
+
+	// SSEServer is a connector.SSEConnector listening on an ephemeral port of the loopback interface.
+	SSEServer struct {
+		// URL is the base HTTP URL without the SSEPath, such as "http://127.0.0.1:54321".
+		URL       string
+		Connector *connector.SSEConnector
+		runner
+	}
+
+	// runner runs a connector.Connector in the background until Close.
+	runner struct {
+		c      connector.Connector
+		cancel context.CancelFunc
+		doneCh chan error
+	}
+)
+
+// StartWebsocketServer starts a connector.WebsocketConnector listening on an ephemeral port of the loopback interface,
+// with its own http.ServeMux and http.Server so that multiple servers can run in the same test process.
+// opts are applied after those, and the caller must call Close to release the port.
+func StartWebsocketServer(opts ...connector.Option) (*WebsocketServer, error) {
+	ln, opts, err := listenLoopback(opts)
+	if err != nil {
+		return nil, err
+	}
+	c := connector.NewWebsocketConnector(opts...)
+	return &WebsocketServer{
+		URL:       "ws://" + ln.Addr().String(),
+		Connector: c,
+		runner:    startRunner(c),
+	}, nil
+}
+
+// StartSSEServer starts a connector.SSEConnector the same way as StartWebsocketServer,
+// and the caller must call Close to release the port.
+func StartSSEServer(opts ...connector.Option) (*SSEServer, error) {
+	ln, opts, err := listenLoopback(opts)
+	if err != nil {
+		return nil, err
+	}
+	c := connector.NewSSEConnector(opts...)
+	return &SSEServer{
+		URL:       "http://" + ln.Addr().String(),
+		Connector: c,
+		runner:    startRunner(c),
+	}, nil
+}
+
+// listenLoopback listens on an ephemeral port of the loopback interface,
+// and returns opts prefixed with the Options serving on it with its own http.ServeMux and http.Server.
+func listenLoopback(opts []connector.Option) (net.Listener, []connector.Option, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("ppctest: net.Listen() error: %w", err)
+	}
+	return ln, append(
+		[]connector.Option{
+			connector.WithHTTPServer(&http.Server{}),
+			connector.WithHTTPServeMux(http.NewServeMux()),
+			connector.WithListener(ln),
+		}, opts...,
+	), nil
+}
+
+func startRunner(c connector.Connector) runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := runner{c: c, cancel: cancel, doneCh: make(chan error, 1)}
+	go func() {
+		r.doneCh <- c.Start(ctx)
+	}()
+	return r
+}
+
+// Close shuts down the server the same way as ppcserver.Server does, and returns the first error.
+func (r *runner) Close() error {
+	r.cancel()
+	shutdownErr := r.c.Shutdown(context.Background())
+	if err := <-r.doneCh; err != nil {
+		return err
+	}
+	return shutdownErr
+}
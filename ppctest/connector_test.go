@@ -0,0 +1,44 @@
+package ppctest_test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net/http"
+	"testing"
+)
+
+func TestStartWebsocketServer(t *testing.T) {
+	s, err := ppctest.StartWebsocketServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, _, err := websocket.DefaultDialer.Dial(s.URL+"/", nil); err == nil {
+		t.Fatal("Dial() succeeds after Close()")
+	}
+}
+
+func TestStartSSEServer(t *testing.T) {
+	s, err := ppctest.StartSSEServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(s.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package ppctest
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"net"
+)
+
+// Server is a ppcserver.Server running in the background with a connector.WebsocketConnector
+// and a connector.SSEConnector, each listening on an ephemeral port of the loopback interface.
+type Server struct {
+	*ppcserver.Server
+
+	// WebsocketURL is the base WebSocket URL without the WebsocketPath, such as "ws://127.0.0.1:54321".
+	WebsocketURL string
+	Websocket    *connector.WebsocketConnector
+	// SSEURL is the base HTTP URL without the SSEPath, such as "http://127.0.0.1:54322".
+	SSEURL string
+	SSE    *connector.SSEConnector
+
+	listeners []net.Listener
+	cancel    context.CancelFunc
+	doneCh    chan struct{}
+}
+
+// StartServer starts a ppcserver.Server with the Components registered by serverOpts,
+// followed by a WebsocketConnector and an SSEConnector created with connOpts as StartWebsocketServer and StartSSEServer do,
+// so that the connectors start after the Components they depend on, see ppcserver.Initializer.
+// The caller must call Close to shut down the Server.
+func StartServer(connOpts []connector.Option, serverOpts ...ppcserver.ServerOption) (*Server, error) {
+	wsLn, wsOpts, err := listenLoopback(connOpts)
+	if err != nil {
+		return nil, err
+	}
+	sseLn, sseOpts, err := listenLoopback(connOpts)
+	if err != nil {
+		_ = wsLn.Close()
+		return nil, err
+	}
+
+	s := &Server{
+		WebsocketURL: "ws://" + wsLn.Addr().String(),
+		Websocket:    connector.NewWebsocketConnector(wsOpts...),
+		SSEURL:       "http://" + sseLn.Addr().String(),
+		SSE:          connector.NewSSEConnector(sseOpts...),
+		listeners:    []net.Listener{wsLn, sseLn},
+		doneCh:       make(chan struct{}),
+	}
+	s.Server = ppcserver.NewServer(
+		append(
+			serverOpts[:len(serverOpts):len(serverOpts)],
+			ppcserver.WithComponent(s.Websocket),
+			ppcserver.WithComponent(s.SSE),
+		)...,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go func() {
+		defer close(s.doneCh)
+		s.Run(ctx)
+	}()
+	return s, nil
+}
+
+// Close shuts down the Server the same way as SIGTERM does, and waits until the shutdown is complete.
+func (s *Server) Close() {
+	s.cancel()
+	<-s.doneCh
+	// The listeners are left open if the Server gives up starting, such as when a Component fails to Init.
+	for _, ln := range s.listeners {
+		_ = ln.Close()
+	}
+}
@@ -0,0 +1,150 @@
+package ppctest_test
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/pom-pom-crafts/ppcserver"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// dependency is a Component the connectors depend on, it records whether Init completes before they accept.
+type dependency struct {
+	initialized int32
+}
+
+func (d *dependency) Init(context.Context) error {
+	atomic.StoreInt32(&d.initialized, 1)
+	return nil
+}
+
+func (d *dependency) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (d *dependency) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestStartServer(t *testing.T) {
+	dep := &dependency{}
+	initializedCh := make(chan bool, 1)
+	s, err := ppctest.StartServer(
+		[]connector.Option{
+			connector.WithOnMessage(
+				func(c *connector.Client, message []byte) {
+					initializedCh <- atomic.LoadInt32(&dep.initialized) == 1
+				},
+			),
+		},
+		ppcserver.WithComponent(dep),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.WebsocketURL+"/", nil)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case initialized := <-initializedCh:
+		if !initialized {
+			t.Fatal("the connector accepts before the Component it depends on is initialized")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the message is not handled")
+	}
+	if !s.Ready() {
+		t.Fatal("Ready() = false while running")
+	}
+
+	resp, err := http.Get(s.SSEURL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("SSE status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	s.Close()
+	if s.Ready() {
+		t.Fatal("Ready() = true after Close()")
+	}
+	// The connection is closed by the shutdown, and the port is released.
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				t.Fatal("the connection is not closed after Close()")
+			}
+			break
+		}
+	}
+	if _, _, err := websocket.DefaultDialer.Dial(s.WebsocketURL+"/", nil); err == nil {
+		t.Fatal("Dial() succeeds after Close()")
+	}
+}
+
+func TestServerReloadRateLimitsOnSIGHUP(t *testing.T) {
+	reloadCh := make(chan struct{}, 2)
+	s, err := ppctest.StartServer(
+		[]connector.Option{
+			connector.WithRateLimitsReload(
+				func(context.Context) (connector.RateLimits, error) {
+					reloadCh <- struct{}{}
+					return connector.RateLimits{AcceptRate: 0.001, AcceptBurst: 1}, nil
+				},
+			),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	dial := func() int {
+		conn, resp, err := websocket.DefaultDialer.Dial(s.WebsocketURL+"/", nil)
+		if err == nil {
+			_ = conn.Close()
+		}
+		if resp == nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		return resp.StatusCode
+	}
+	for i := 0; i < 3; i++ {
+		if got := dial(); got != http.StatusSwitchingProtocols {
+			t.Fatalf("status before SIGHUP = %d, want %d", got, http.StatusSwitchingProtocols)
+		}
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	// Both connectors reload in the order they are registered, the WebsocketConnector applies the limits first.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-reloadCh:
+		case <-time.After(time.Second):
+			t.Fatal("the rate limits are not reloaded on SIGHUP")
+		}
+	}
+	if got := dial(); got != http.StatusSwitchingProtocols {
+		t.Fatalf("status of the burst after SIGHUP = %d, want %d", got, http.StatusSwitchingProtocols)
+	}
+	if got := dial(); got != http.StatusTooManyRequests {
+		t.Fatalf("status over the limit after SIGHUP = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,116 @@
+// Package ppctest provides utilities for testing applications built on ppcserver,
+// with in-memory transports, a fake clock, and connectors or a whole Server listening on ephemeral loopback ports.
+package ppctest
+
+import (
+	"context"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"net"
+	"sync"
+)
+
+// TransportProtocolTypeMemory is the protocol type of Transport.
+const TransportProtocolTypeMemory connector.TransportProtocolType = "memory"
+
+// Transport is an in-memory connector.Transport, whose peer side is driven by the test through Send and Receive.
+type Transport struct {
+	toServerCh chan []byte
+	toPeerCh   chan []byte
+	closeOnce  sync.Once
+	closedCh   chan struct{}
+}
+
+// NewTransport creates a new Transport, buffer is the number of messages each direction holds before blocking.
+func NewTransport(buffer int) *Transport {
+	return &Transport{
+		toServerCh: make(chan []byte, buffer),
+		toPeerCh:   make(chan []byte, buffer),
+		closedCh:   make(chan struct{}),
+	}
+}
+
+// ProtocolType returns TransportProtocolTypeMemory.
+func (t *Transport) ProtocolType() connector.TransportProtocolType {
+	return TransportProtocolTypeMemory
+}
+
+// NetConn returns nil, as there is no underlying network connection.
+func (t *Transport) NetConn() net.Conn {
+	return nil
+}
+
+// Read blocks until the peer sends a message via Send, or returns net.ErrClosed once the Transport is closed.
+func (t *Transport) Read() ([]byte, error) {
+	select {
+	case data := <-t.toServerCh:
+		return data, nil
+	case <-t.closedCh:
+		return nil, net.ErrClosed
+	}
+}
+
+// Write blocks until the message is buffered for the peer to Receive, or returns net.ErrClosed once the Transport is closed.
+func (t *Transport) Write(data []byte) error {
+	select {
+	case <-t.closedCh:
+		return net.ErrClosed
+	default:
+	}
+
+	select {
+	case t.toPeerCh <- data:
+		return nil
+	case <-t.closedCh:
+		return net.ErrClosed
+	}
+}
+
+// Close closes the Transport, it is safe to call more than once.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(
+		func() {
+			close(t.closedCh)
+		},
+	)
+	return nil
+}
+
+// Closed returns a channel that is closed once the Transport is closed by either side.
+func (t *Transport) Closed() <-chan struct{} {
+	return t.closedCh
+}
+
+// Send sends data from the peer side, to be returned by Read.
+func (t *Transport) Send(data []byte) error {
+	select {
+	case <-t.closedCh:
+		return net.ErrClosed
+	default:
+	}
+
+	select {
+	case t.toServerCh <- data:
+		return nil
+	case <-t.closedCh:
+		return net.ErrClosed
+	}
+}
+
+// Receive returns the next message written to the peer side.
+// The messages written before the Transport is closed can still be received after closing.
+func (t *Transport) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-t.toPeerCh:
+		return data, nil
+	default:
+	}
+
+	select {
+	case data := <-t.toPeerCh:
+		return data, nil
+	case <-t.closedCh:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,82 @@
+package ppctest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/connector"
+	"github.com/pom-pom-crafts/ppcserver/ppctest"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	transport := ppctest.NewTransport(1)
+
+	if err := transport.Send([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := transport.Read(); err != nil || string(got) != "ping" {
+		t.Fatalf("Read() = %q, %v, want %q", got, err, "ping")
+	}
+
+	if err := transport.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	_ = transport.Close()
+	// The messages written before closing can still be received.
+	if got, err := transport.Receive(ctx); err != nil || string(got) != "pong" {
+		t.Fatalf("Receive() = %q, %v, want %q", got, err, "pong")
+	}
+
+	select {
+	case <-transport.Closed():
+	default:
+		t.Fatal("Closed() is not closed after Close()")
+	}
+	if _, err := transport.Read(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Read() error = %v, want %v", err, net.ErrClosed)
+	}
+	if err := transport.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Write() error = %v, want %v", err, net.ErrClosed)
+	}
+	if err := transport.Send([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Send() error = %v, want %v", err, net.ErrClosed)
+	}
+	if _, err := transport.Receive(ctx); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Receive() error = %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestTransportStartClient(t *testing.T) {
+	transport := ppctest.NewTransport(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.StartClient(ctx, transport, connector.WithShutdownMessage([]byte("bye")))
+	}()
+
+	// The Client reads what the peer sends, and writes the shutdown message to the peer when ctx is done.
+	if err := transport.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	receiveCtx, cancelReceive := context.WithTimeout(context.Background(), time.Second)
+	defer cancelReceive()
+	if got, err := transport.Receive(receiveCtx); err != nil || string(got) != "bye" {
+		t.Fatalf("Receive() = %q, %v, want %q", got, err, "bye")
+	}
+
+	select {
+	case <-errCh:
+	case <-time.After(time.Second):
+		t.Fatal("StartClient() does not return after ctx is done")
+	}
+	select {
+	case <-transport.Closed():
+	default:
+		t.Fatal("the Transport is not closed after StartClient() returns")
+	}
+}
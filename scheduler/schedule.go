@@ -0,0 +1,49 @@
+package scheduler
+
+import "time"
+
+type (
+	// Schedule describes when a job should run.
+	Schedule interface {
+		// Next should return the next time to run after now.
+		Next(now time.Time) time.Time
+	}
+
+	everySchedule time.Duration
+
+	dailySchedule struct {
+		hour, minute int
+		loc          *time.Location
+	}
+)
+
+// Every returns a Schedule that runs every interval d, starting d after the Scheduler starts.
+// Every panics if d <= 0, as time.NewTicker does, since the job would otherwise run back to back.
+func Every(d time.Duration) Schedule {
+	if d <= 0 {
+		panic("ppcserver: non-positive interval for scheduler.Every")
+	}
+	return everySchedule(d)
+}
+
+func (s everySchedule) Next(now time.Time) time.Time {
+	return now.Add(time.Duration(s))
+}
+
+// Daily returns a Schedule that runs once a day at hour:minute in loc, such as for daily resets.
+// time.Local is used if loc is nil.
+func Daily(hour, minute int, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.Local
+	}
+	return dailySchedule{hour: hour, minute: minute, loc: loc}
+}
+
+func (s dailySchedule) Next(now time.Time) time.Time {
+	now = now.In(s.loc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.loc)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
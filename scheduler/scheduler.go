@@ -0,0 +1,143 @@
+// Package scheduler provides a Component that runs periodic jobs, such as leaderboard rollups,
+// idle room garbage collection, and daily resets, along with the Server lifecycle.
+// In a cluster, set a LeaderElector via WithLeaderElector to run the jobs on one node only.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type (
+	// Job is the function run by the Scheduler, ctx is done when the server is shutting down.
+	Job func(ctx context.Context) error
+
+	// Option is a function to apply various configurations to customize a Scheduler.
+	Option func(s *Scheduler)
+
+	// LeaderElector decides which node of a cluster runs the jobs, so that a job such as a daily reset runs once
+	// instead of once per node. It is backed by the cluster's coordination, such as a leader election over NATS
+	// or a lock with a TTL in a shared storage.
+	// Implementations must be safe for concurrent use.
+	LeaderElector interface {
+		// IsLeader should report whether this node is the leader, it is called every time a job is due.
+		IsLeader(ctx context.Context) (bool, error)
+	}
+
+	// LeaderElectorFunc is an adapter to use an ordinary function as a LeaderElector.
+	LeaderElectorFunc func(ctx context.Context) (bool, error)
+
+	job struct {
+		name     string
+		schedule Schedule
+		run      Job
+	}
+
+	// Scheduler is a Component that runs the registered jobs by their Schedule from Start until the server shuts down.
+	// Each job runs in its own goroutine, and a job never overlaps with its own previous run.
+	// Every node runs every job, unless a LeaderElector is set via WithLeaderElector.
+	Scheduler struct {
+		jobs   []*job
+		jobsWg sync.WaitGroup
+		leader LeaderElector // leader is nil if every node runs the jobs.
+	}
+)
+
+// NewScheduler creates a new Scheduler.
+func NewScheduler(opts ...Option) *Scheduler {
+	s := &Scheduler{}
+
+	// Apply opts to customize Scheduler.
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Start runs every job by its Schedule and blocks until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) error {
+	for _, j := range s.jobs {
+		s.jobsWg.Add(1)
+		go func(j *job) {
+			defer s.jobsWg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// Shutdown waits for the running jobs to return, or until ctx is done.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	doneCh := make(chan struct{})
+	go func() {
+		s.jobsWg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsLeader calls f(ctx).
+func (f LeaderElectorFunc) IsLeader(ctx context.Context) (bool, error) {
+	return f(ctx)
+}
+
+// loop runs j every time its Schedule is due, until ctx is done.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		timer := time.NewTimer(time.Until(j.schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.isLeader(ctx, j) {
+			continue
+		}
+		// A failed run is only logged, the job will still run by its next Schedule.
+		if err := j.run(ctx); err != nil {
+			log.Printf("ppcserver: scheduler job %q error: %v", j.name, err)
+		}
+	}
+}
+
+// isLeader reports whether this node should run j now, which is always true without a LeaderElector.
+// The run is skipped if the LeaderElector fails, as running it on more than one node is usually worse than a delay.
+func (s *Scheduler) isLeader(ctx context.Context, j *job) bool {
+	if s.leader == nil {
+		return true
+	}
+	leader, err := s.leader.IsLeader(ctx)
+	if err != nil {
+		log.Printf("ppcserver: scheduler job %q skipped, LeaderElector.IsLeader() error: %v", j.name, err)
+		return false
+	}
+	return leader
+}
+
+// WithJob is an Option to register a job named name that runs fn by schedule.
+func WithJob(name string, schedule Schedule, fn Job) Option {
+	return func(s *Scheduler) {
+		s.jobs = append(s.jobs, &job{name: name, schedule: schedule, run: fn})
+	}
+}
+
+// WithLeaderElector is an Option to run the jobs only while e reports this node as the leader,
+// so that a job runs on one node only in a cluster.
+func WithLeaderElector(e LeaderElector) Option {
+	return func(s *Scheduler) {
+		s.leader = e
+	}
+}
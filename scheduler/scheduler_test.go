@@ -0,0 +1,195 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"github.com/pom-pom-crafts/ppcserver/scheduler"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got, want := scheduler.Every(time.Minute).Next(now), now.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("Next() = %s, want %s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Every(0) does not panic")
+		}
+	}()
+	scheduler.Every(0)
+}
+
+func TestDailyNext(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	daily := scheduler.Daily(4, 30, tokyo)
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"earlier today", time.Date(2022, 1, 1, 4, 0, 0, 0, tokyo), time.Date(2022, 1, 1, 4, 30, 0, 0, tokyo)},
+		{"exactly due", time.Date(2022, 1, 1, 4, 30, 0, 0, tokyo), time.Date(2022, 1, 2, 4, 30, 0, 0, tokyo)},
+		{"later today", time.Date(2022, 1, 1, 23, 59, 0, 0, tokyo), time.Date(2022, 1, 2, 4, 30, 0, 0, tokyo)},
+		{"month rollover", time.Date(2022, 1, 31, 5, 0, 0, 0, tokyo), time.Date(2022, 2, 1, 4, 30, 0, 0, tokyo)},
+		{"year rollover", time.Date(2022, 12, 31, 5, 0, 0, 0, tokyo), time.Date(2023, 1, 1, 4, 30, 0, 0, tokyo)},
+		// 20:00 UTC is already 05:00 of the next day in Tokyo.
+		{"another zone", time.Date(2022, 1, 1, 20, 0, 0, 0, time.UTC), time.Date(2022, 1, 3, 4, 30, 0, 0, tokyo)},
+	}
+	for _, tt := range tests {
+		if got := daily.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.now, got, tt.want)
+		}
+	}
+}
+
+// startScheduler starts s in the background, and returns the function to stop it as the Server does.
+func startScheduler(t *testing.T, s *scheduler.Scheduler, shutdownTimeout time.Duration) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- s.Start(ctx)
+	}()
+	return func() error {
+		cancel()
+		if err := <-doneCh; err != nil {
+			return err
+		}
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+func TestSchedulerNoOverlap(t *testing.T) {
+	var running, maxRunning, runs int32
+	s := scheduler.NewScheduler(
+		scheduler.WithJob(
+			"slow", scheduler.Every(time.Millisecond), func(ctx context.Context) error {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				atomic.AddInt32(&runs, 1)
+				time.Sleep(10 * time.Millisecond)
+				return errors.New("failed runs are retried by the schedule")
+			},
+		),
+	)
+	stop := startScheduler(t, s, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt32(&runs); got < 2 {
+		t.Fatalf("runs = %d, want at least 2", got)
+	}
+	if got := atomic.LoadInt32(&maxRunning); got != 1 {
+		t.Fatalf("concurrent runs = %d, want 1", got)
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	var once sync.Once
+	s := scheduler.NewScheduler(
+		scheduler.WithJob(
+			"stuck", scheduler.Every(time.Millisecond), func(context.Context) error {
+				once.Do(func() { close(startedCh) })
+				// Ignores ctx, as a job in the middle of a non-cancelable operation.
+				<-releaseCh
+				return nil
+			},
+		),
+	)
+	stop := startScheduler(t, s, 20*time.Millisecond)
+	<-startedCh
+	if err := stop(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() with the job in flight = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(releaseCh)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() after the job returns = %v, want nil", err)
+	}
+}
+
+func TestSchedulerLeaderElector(t *testing.T) {
+	var leader, runs int32
+	s := scheduler.NewScheduler(
+		scheduler.WithLeaderElector(
+			scheduler.LeaderElectorFunc(
+				func(context.Context) (bool, error) {
+					return atomic.LoadInt32(&leader) == 1, nil
+				},
+			),
+		),
+		scheduler.WithJob(
+			"reset", scheduler.Every(time.Millisecond), func(context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		),
+	)
+	stop := startScheduler(t, s, time.Second)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&runs); got != 0 {
+		t.Fatalf("runs while not the leader = %d, want 0", got)
+	}
+
+	atomic.StoreInt32(&leader, 1)
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&runs) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the job does not run after becoming the leader")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerLeaderElectorError(t *testing.T) {
+	var calls, runs int32
+	s := scheduler.NewScheduler(
+		scheduler.WithLeaderElector(
+			scheduler.LeaderElectorFunc(
+				func(context.Context) (bool, error) {
+					atomic.AddInt32(&calls, 1)
+					return true, errors.New("election unavailable")
+				},
+			),
+		),
+		scheduler.WithJob(
+			"reset", scheduler.Every(time.Millisecond), func(context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		),
+	)
+	stop := startScheduler(t, s, time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Fatal("the LeaderElector is not consulted")
+	}
+	if got := atomic.LoadInt32(&runs); got != 0 {
+		t.Fatalf("runs while the LeaderElector fails = %d, want 0", got)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"golang.org/x/sync/errgroup"
 	"log"
+	"net/http"
+	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -19,6 +22,12 @@ type (
 		// ShutdownTimeout is the maximum time for Component.Shutdown() to complete.
 		// Defaults to 1 minute if not set via WithShutdownTimeout.
 		ShutdownTimeout time.Duration
+
+		// DrainDelay is the time to wait after SIGINT/SIGTERM is received before shutting down the components,
+		// during which Server.Ready reports false while the components keep serving,
+		// so that the load balancers have time to deregister the server, such as on Kubernetes.
+		// Defaults to 0 if not set via WithDrainDelay, which shuts down the components immediately.
+		DrainDelay time.Duration
 	}
 
 	Component interface {
@@ -26,9 +35,32 @@ type (
 		Shutdown(ctx context.Context) error
 	}
 
+	// Initializer is an optional interface for a Component to get ready before any Component starts,
+	// such as connecting to a broker before a connector accepts any traffic.
+	// Init is invoked one by one in the order the Components are registered,
+	// so a Component may depend on the Components registered before it.
+	Initializer interface {
+		Init(ctx context.Context) error
+	}
+
+	// Reloader is an optional interface for a Component to apply its configurations at runtime.
+	// Reload is invoked by Server.Reload, which happens on SIGHUP or whenever the application calls it.
+	// The connectors implement it to reload their rate limits, see connector.WithRateLimitsReload.
+	Reloader interface {
+		Reload(ctx context.Context) error
+	}
+
+	// ReloadFunc is subscribed via WithReloadFunc to apply configurations at runtime,
+	// such as re-reading a config file and then calling connector.SetMaxClients.
+	ReloadFunc func(ctx context.Context) error
+
 	Server struct {
-		opts       *ServerOptions
-		components []Component
+		ready int32 // ready is 1 from Start until a shutdown signal is received, accessed atomically.
+
+		opts          *ServerOptions
+		components    []Component
+		componentOpts []*ComponentOptions // componentOpts[i] is the ComponentOptions of components[i].
+		reloadFuncs   []ReloadFunc
 	}
 )
 
@@ -51,24 +83,92 @@ func NewServer(opts ...ServerOption) *Server {
 	return s
 }
 
+// Start starts all the components and blocks until they are shut down after SIGINT/SIGTERM is received.
 func (s *Server) Start() {
-	// The ctx.Done channel returns from signal.NotifyContext() will be closed when SIGINT/SIGTERM signal is received.
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	s.Run(context.Background())
+}
+
+// Run is Start, except that ctx being done also shuts down the components the same way as SIGINT/SIGTERM does,
+// such as for a test to stop the Server, see ppctest.StartServer.
+func (s *Server) Run(ctx context.Context) {
+	// The sigCtx.Done channel returns from signal.NotifyContext() will be closed when SIGINT/SIGTERM signal is received,
+	// or ctx is done.
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// The ctx.Done channel returns from errgroup.WithContext() will be closed when SIGINT/SIGTERM signal is received,
+	// Catch SIGHUP until Start returns, including the initialization and the shutdown,
+	// since the default action of SIGHUP terminates the process, such as in the middle of draining.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	// Initialize all the components before starting any, and give up starting if any fails.
+	if err := s.initComponents(sigCtx); err != nil {
+		log.Println("ppcserver: server start aborted:", err)
+		return
+	}
+
+	// The drainCtx.Done channel will be closed when DrainDelay has passed since SIGINT/SIGTERM signal is received.
+	drainCtx, drained := context.WithCancel(context.Background())
+	defer drained()
+
+	// The ctx.Done channel returns from errgroup.WithContext() will be closed when drainCtx.Done is closed,
 	// or the first time any Component.Start() method which passed to g.Go() returns a non-nil error,
 	// or g.Wait() returns, whichever occurs first.
-	g, ctx := errgroup.WithContext(ctx)
-	for _, c := range s.components {
+	g, ctx := errgroup.WithContext(drainCtx)
+	atomic.StoreInt32(&s.ready, 1)
+	g.Go(
+		func() error {
+			select {
+			case <-sigCtx.Done():
+			case <-ctx.Done():
+				// A Component fails before any signal is received, there is nothing left to drain.
+				atomic.StoreInt32(&s.ready, 0)
+				return nil
+			}
+
+			// Report not ready first, and keep serving until the load balancers stop routing to this server.
+			atomic.StoreInt32(&s.ready, 0)
+			if s.opts.DrainDelay > 0 {
+				log.Printf("ppcserver: shutdown signal received, draining for %s", s.opts.DrainDelay)
+				timer := time.NewTimer(s.opts.DrainDelay)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+				}
+			}
+			drained()
+			return nil
+		},
+	)
+	g.Go(
+		func() error {
+			// Reload on every SIGHUP until the server is shutting down, including draining, after which SIGHUP is ignored.
+			for {
+				select {
+				case <-sigCtx.Done():
+					return nil
+				case <-ctx.Done():
+					return nil
+				case <-hupCh:
+					log.Println("ppcserver: SIGHUP received, reloading")
+					// Reload errors are only logged, as a bad config should not take down a running server.
+					if err := s.Reload(ctx); err != nil {
+						log.Println("ppcserver: server reload complete with error:", err)
+					}
+				}
+			}
+		},
+	)
+	for i, c := range s.components {
+		c, opts := c, s.componentOpts[i] // Capture the loop variables for the closures below.
+
 		// g.Go(f func() error) runs each f in a goroutine.
 		g.Go(
 			func() error {
-				// TODO, should we recover panic and log with error here?
-
-				// Component.Start() may block here, and its implementation should return when ctx.Done is closed.
-				log.Printf("ppcserver: starting component: %T", c)
-				return c.Start(ctx)
+				// superviseComponent applies the FailurePolicy of c, and returns an error only when it is fatal.
+				return superviseComponent(ctx, c, opts)
 			},
 		)
 		g.Go(
@@ -89,7 +189,10 @@ func (s *Server) Start() {
 					shutdownErrCh <- timeoutCtx.Err()
 				case shutdownErrCh <- c.Shutdown(timeoutCtx):
 				}
-				return fmt.Errorf("ppcserver: %T.Shutdown() error: %w", c, <-shutdownErrCh)
+				if err := <-shutdownErrCh; err != nil {
+					return fmt.Errorf("ppcserver: %T.Shutdown() error: %w", c, err)
+				}
+				return nil
 			},
 		)
 	}
@@ -101,10 +204,88 @@ func (s *Server) Start() {
 	}
 }
 
-// WithComponent is a ServerOption to register a Component to Server.components.
-func WithComponent(c Component) ServerOption {
+// initComponents invokes Init of every Component that implements Initializer in the order they are registered.
+// If any fails, the Components before it are shut down in the reverse order, and the error is returned.
+func (s *Server) initComponents(ctx context.Context) error {
+	for i, c := range s.components {
+		initializer, ok := c.(Initializer)
+		if !ok {
+			continue
+		}
+
+		log.Printf("ppcserver: initializing component: %T", c)
+		if err := initializer.Init(ctx); err != nil {
+			timeoutCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+			defer cancel()
+			for j := i - 1; j >= 0; j-- {
+				log.Printf("ppcserver: shutting down component: %T", s.components[j])
+				if err := s.components[j].Shutdown(timeoutCtx); err != nil {
+					log.Printf("ppcserver: %T.Shutdown() error: %v", s.components[j], err)
+				}
+			}
+			return fmt.Errorf("ppcserver: %T.Init() error: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// Ready reports whether the Server is started and not shutting down, for a readiness probe to check.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// ReadinessHandler returns an http.Handler responding 200 OK if Server.Ready, or 503 Service Unavailable otherwise.
+// Mount it on the path of the readiness probe, such as http.Handle("/readyz", s.ReadinessHandler()).
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			if !s.Ready() {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		},
+	)
+}
+
+// Reload invokes every ReloadFunc in the order they are registered, and then every Component that implements Reloader.
+// All of them are invoked even if some return errors, and the first error is returned.
+// Reload is safe to call from any goroutine, such as from an admin API handler.
+func (s *Server) Reload(ctx context.Context) error {
+	var firstErr error
+	recordErr := func(err error) {
+		log.Println(err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, f := range s.reloadFuncs {
+		if err := f(ctx); err != nil {
+			recordErr(fmt.Errorf("ppcserver: ReloadFunc error: %w", err))
+		}
+	}
+	for _, c := range s.components {
+		if r, ok := c.(Reloader); ok {
+			if err := r.Reload(ctx); err != nil {
+				recordErr(fmt.Errorf("ppcserver: %T.Reload() error: %w", c, err))
+			}
+		}
+	}
+	return firstErr
+}
+
+// WithComponent is a ServerOption to register a Component to Server.components,
+// opts customizes how the Server runs it, such as WithFailurePolicy.
+// Register a Component after the ones it depends on, see Initializer.
+func WithComponent(c Component, opts ...ComponentOption) ServerOption {
 	return func(s *Server) {
+		o := defaultComponentOptions()
+		for _, opt := range opts {
+			opt(o)
+		}
 		s.components = append(s.components, c)
+		s.componentOpts = append(s.componentOpts, o)
 	}
 }
 
@@ -114,3 +295,20 @@ func WithShutdownTimeout(d time.Duration) ServerOption {
 		s.opts.ShutdownTimeout = d
 	}
 }
+
+// WithReloadFunc is a ServerOption to subscribe f to Server.Reload.
+func WithReloadFunc(f ReloadFunc) ServerOption {
+	return func(s *Server) {
+		s.reloadFuncs = append(s.reloadFuncs, f)
+	}
+}
+
+// WithDrainDelay is a ServerOption to set the time to wait after SIGINT/SIGTERM before shutting down the components.
+// Set it longer than the time the load balancer takes to deregister the server after the readiness probe fails,
+// such as periodSeconds * failureThreshold of the Kubernetes readinessProbe.
+// The total of DrainDelay and ShutdownTimeout should be within the terminationGracePeriodSeconds of the Pod.
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.opts.DrainDelay = d
+	}
+}
@@ -0,0 +1,328 @@
+package ppcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reloadComponent is a Component that implements Reloader, and runs initFunc on Init if not nil.
+type reloadComponent struct {
+	initFunc func(ctx context.Context) error
+	reloadCh chan struct{}
+}
+
+func newReloadComponent() *reloadComponent {
+	return &reloadComponent{reloadCh: make(chan struct{}, 1)}
+}
+
+func (c *reloadComponent) Init(ctx context.Context) error {
+	if c.initFunc != nil {
+		return c.initFunc(ctx)
+	}
+	return nil
+}
+
+func (c *reloadComponent) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *reloadComponent) Shutdown(context.Context) error {
+	return nil
+}
+
+func (c *reloadComponent) Reload(context.Context) error {
+	select {
+	case c.reloadCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// runServer runs s in the background until the returned cancel is called, which waits until Run returns.
+func runServer(s *Server) (cancel func()) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		s.Run(ctx)
+	}()
+	return func() {
+		cancelCtx()
+		<-doneCh
+	}
+}
+
+// waitReady waits until s reports ready.
+func waitReady(t *testing.T, s *Server) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !s.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("the Server is not ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// sighup sends SIGHUP to the test process, which terminates it unless the Server catches SIGHUP.
+func sighup(t *testing.T) {
+	t.Helper()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerReloadOnSIGHUP(t *testing.T) {
+	c := newReloadComponent()
+	reloadFuncCh := make(chan struct{}, 1)
+	s := NewServer(
+		WithComponent(c),
+		WithReloadFunc(
+			func(context.Context) error {
+				reloadFuncCh <- struct{}{}
+				return nil
+			},
+		),
+	)
+	cancel := runServer(s)
+	defer cancel()
+	waitReady(t, s)
+
+	sighup(t)
+	for _, ch := range []chan struct{}{reloadFuncCh, c.reloadCh} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("Reload is not invoked on SIGHUP")
+		}
+	}
+}
+
+func TestServerIgnoresSIGHUPDuringInit(t *testing.T) {
+	c := newReloadComponent()
+	c.initFunc = func(context.Context) error {
+		sighup(t)
+		// Give the signal time to be delivered while still initializing.
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	s := NewServer(WithComponent(c))
+	cancel := runServer(s)
+	defer cancel()
+
+	// The process survives the SIGHUP to get here.
+	waitReady(t, s)
+}
+
+func TestServerIgnoresSIGHUPDuringDrain(t *testing.T) {
+	c := newReloadComponent()
+	s := NewServer(WithComponent(c), WithDrainDelay(100*time.Millisecond))
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		s.Run(ctx)
+	}()
+	waitReady(t, s)
+
+	cancelCtx()
+	for s.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	sighup(t)
+
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("Run() does not return after the drain")
+	}
+	select {
+	case <-c.reloadCh:
+		t.Fatal("Reload is invoked while draining")
+	default:
+	}
+}
+
+// shutdownRecorder is a Component recording the time Shutdown is invoked.
+type shutdownRecorder struct {
+	shutdownCh chan time.Time
+}
+
+func (c *shutdownRecorder) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *shutdownRecorder) Shutdown(context.Context) error {
+	c.shutdownCh <- time.Now()
+	return nil
+}
+
+// readinessStatus returns the HTTP status responded by the ReadinessHandler of s.
+func readinessStatus(s *Server) int {
+	w := httptest.NewRecorder()
+	s.ReadinessHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	return w.Code
+}
+
+func TestServerDrainDelay(t *testing.T) {
+	const drainDelay = 100 * time.Millisecond
+	c := &shutdownRecorder{shutdownCh: make(chan time.Time, 1)}
+	s := NewServer(WithComponent(c), WithDrainDelay(drainDelay))
+	if s.Ready() {
+		t.Fatal("Ready() = true before Run()")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		s.Run(ctx)
+	}()
+	waitReady(t, s)
+	if got := readinessStatus(s); got != http.StatusOK {
+		t.Fatalf("readiness status = %d, want %d", got, http.StatusOK)
+	}
+
+	signaled := time.Now()
+	cancel()
+	for s.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	if got := readinessStatus(s); got != http.StatusServiceUnavailable {
+		t.Fatalf("readiness status while draining = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	// The Component keeps serving while draining.
+	select {
+	case <-c.shutdownCh:
+		t.Fatal("the Component is shut down while draining")
+	default:
+	}
+
+	select {
+	case shutdown := <-c.shutdownCh:
+		if d := shutdown.Sub(signaled); d < drainDelay {
+			t.Fatalf("the Component is shut down %s after the signal, want at least the DrainDelay %s", d, drainDelay)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the Component is not shut down after the drain")
+	}
+	<-doneCh
+}
+
+func TestServerComponentFailureSkipsDrain(t *testing.T) {
+	s := NewServer(
+		WithComponent(
+			&fakeComponent{
+				startFunc: func(context.Context, int) error {
+					return errComponentFailed
+				},
+			},
+		),
+		WithDrainDelay(time.Hour),
+	)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		s.Run(context.Background())
+	}()
+
+	// A failing Component shuts the Server down without waiting for the DrainDelay.
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("Run() waits for the DrainDelay after the Component fails")
+	}
+	if s.Ready() {
+		t.Fatal("Ready() = true after the Component fails")
+	}
+}
+
+// initComponent is a Component appending its name to events on Init, Start and Shutdown, and failing Init with initErr.
+type initComponent struct {
+	name    string
+	initErr error
+	mu      *sync.Mutex
+	events  *[]string
+}
+
+func (c *initComponent) record(event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.events = append(*c.events, c.name+"."+event)
+}
+
+func (c *initComponent) Init(context.Context) error {
+	c.record("Init")
+	return c.initErr
+}
+
+func (c *initComponent) Start(ctx context.Context) error {
+	c.record("Start")
+	<-ctx.Done()
+	return nil
+}
+
+func (c *initComponent) Shutdown(context.Context) error {
+	c.record("Shutdown")
+	return nil
+}
+
+func TestServerInitOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	s := NewServer(
+		WithComponent(&initComponent{name: "a", mu: &mu, events: &events}),
+		// A Component not implementing Initializer is skipped.
+		WithComponent(
+			&fakeComponent{
+				startFunc: func(ctx context.Context, _ int) error {
+					<-ctx.Done()
+					return nil
+				},
+			},
+		),
+		WithComponent(&initComponent{name: "b", mu: &mu, events: &events}),
+	)
+	cancel := runServer(s)
+	waitReady(t, s)
+	cancel()
+
+	// Every Init completes in the registration order before any Component starts.
+	if len(events) < 4 || events[0] != "a.Init" || events[1] != "b.Init" {
+		t.Fatalf("events = %v, want a.Init and b.Init before any Start", events)
+	}
+	for _, event := range events[2:] {
+		if strings.HasSuffix(event, ".Init") {
+			t.Fatalf("events = %v, want no Init after a Start", events)
+		}
+	}
+}
+
+func TestServerInitFailure(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	s := NewServer(
+		WithComponent(&initComponent{name: "a", mu: &mu, events: &events}),
+		WithComponent(&initComponent{name: "b", mu: &mu, events: &events}),
+		WithComponent(&initComponent{name: "c", initErr: errComponentFailed, mu: &mu, events: &events}),
+		WithComponent(&initComponent{name: "d", mu: &mu, events: &events}),
+	)
+	s.Run(context.Background())
+
+	// The Components initialized before the failing one are shut down in the reverse order, and none starts.
+	want := []string{"a.Init", "b.Init", "c.Init", "b.Shutdown", "a.Shutdown"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if s.Ready() {
+		t.Fatal("Ready() = true after Init fails")
+	}
+}
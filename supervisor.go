@@ -0,0 +1,111 @@
+package ppcserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// FailurePolicyFatal shuts down the Server when the Component fails. This is the default.
+	FailurePolicyFatal FailurePolicy = iota
+	// FailurePolicyRestart restarts the Component with exponential backoff when it fails.
+	FailurePolicyRestart
+	// FailurePolicyIgnore logs the failure and leaves the Component stopped, while the Server keeps running.
+	FailurePolicyIgnore
+)
+
+type (
+	// FailurePolicy decides what the Server does when Component.Start() returns a non-nil error or panics.
+	FailurePolicy uint8
+
+	// ComponentOption is a function to apply various configurations to customize how the Server runs a Component.
+	ComponentOption func(o *ComponentOptions)
+
+	// ComponentOptions defines the configurable opts of how the Server runs a Component.
+	ComponentOptions struct {
+		// FailurePolicy decides what happens when the Component fails.
+		// Defaults to FailurePolicyFatal if not set via WithFailurePolicy.
+		FailurePolicy FailurePolicy
+
+		// MinRestartBackoff and MaxRestartBackoff bound the wait before each restart with FailurePolicyRestart,
+		// the wait starts at MinRestartBackoff and doubles on each consecutive failure up to MaxRestartBackoff.
+		// Defaults to 1 second and 1 minute if not set via WithRestartBackoff.
+		MinRestartBackoff time.Duration
+		MaxRestartBackoff time.Duration
+	}
+)
+
+func defaultComponentOptions() *ComponentOptions {
+	return &ComponentOptions{
+		FailurePolicy:     FailurePolicyFatal,
+		MinRestartBackoff: 1 * time.Second,
+		MaxRestartBackoff: 1 * time.Minute,
+	}
+}
+
+// superviseComponent runs c.Start() and applies opts.FailurePolicy whenever it fails, until ctx is done.
+// A panic in c.Start() is recovered and treated as a failure.
+// A non-nil error is returned only with FailurePolicyFatal, which shuts down the Server.
+func superviseComponent(ctx context.Context, c Component, opts *ComponentOptions) error {
+	backoff := opts.MinRestartBackoff
+	for {
+		// Component.Start() may block here, and its implementation should return when ctx.Done is closed.
+		log.Printf("ppcserver: starting component: %T", c)
+		startedAt := time.Now()
+		err := startComponent(ctx, c)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		switch opts.FailurePolicy {
+		case FailurePolicyIgnore:
+			log.Printf("ppcserver: component %T failed and is ignored: %v", c, err)
+			return nil
+		case FailurePolicyRestart:
+			// A Component that ran longer than MaxRestartBackoff is considered recovered, so the backoff starts over.
+			if time.Since(startedAt) > opts.MaxRestartBackoff {
+				backoff = opts.MinRestartBackoff
+			}
+			log.Printf("ppcserver: component %T failed, restarting in %s: %v", c, backoff, err)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+			if backoff *= 2; backoff > opts.MaxRestartBackoff {
+				backoff = opts.MaxRestartBackoff
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// startComponent invokes c.Start() and converts a panic into an error.
+func startComponent(ctx context.Context, c Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("ppcserver: %T.Start() panic: %v", c, r)
+		}
+	}()
+	return c.Start(ctx)
+}
+
+// WithFailurePolicy is a ComponentOption to set what the Server does when the Component fails.
+func WithFailurePolicy(p FailurePolicy) ComponentOption {
+	return func(o *ComponentOptions) {
+		o.FailurePolicy = p
+	}
+}
+
+// WithRestartBackoff is a ComponentOption to set the bounds of the wait before each restart with FailurePolicyRestart.
+func WithRestartBackoff(min, max time.Duration) ComponentOption {
+	return func(o *ComponentOptions) {
+		o.MinRestartBackoff = min
+		o.MaxRestartBackoff = max
+	}
+}
@@ -0,0 +1,153 @@
+package ppcserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeComponent is a Component whose Start runs startFunc with the number of the start, counting from 0.
+type fakeComponent struct {
+	mu        sync.Mutex
+	starts    []time.Time
+	startFunc func(ctx context.Context, n int) error
+}
+
+func (c *fakeComponent) Start(ctx context.Context) error {
+	c.mu.Lock()
+	n := len(c.starts)
+	c.starts = append(c.starts, time.Now())
+	c.mu.Unlock()
+	return c.startFunc(ctx, n)
+}
+
+func (c *fakeComponent) Shutdown(context.Context) error {
+	return nil
+}
+
+func (c *fakeComponent) numStarts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.starts)
+}
+
+var errComponentFailed = errors.New("component failed")
+
+func TestSuperviseComponentFatal(t *testing.T) {
+	c := &fakeComponent{
+		startFunc: func(context.Context, int) error {
+			return errComponentFailed
+		},
+	}
+	err := superviseComponent(context.Background(), c, defaultComponentOptions())
+	if !errors.Is(err, errComponentFailed) {
+		t.Fatalf("superviseComponent() = %v, want %v", err, errComponentFailed)
+	}
+	if n := c.numStarts(); n != 1 {
+		t.Fatalf("started %d times, want 1", n)
+	}
+}
+
+func TestSuperviseComponentIgnore(t *testing.T) {
+	c := &fakeComponent{
+		startFunc: func(context.Context, int) error {
+			panic("boom")
+		},
+	}
+	opts := defaultComponentOptions()
+	WithFailurePolicy(FailurePolicyIgnore)(opts)
+	if err := superviseComponent(context.Background(), c, opts); err != nil {
+		t.Fatalf("superviseComponent() = %v, want nil", err)
+	}
+	if n := c.numStarts(); n != 1 {
+		t.Fatalf("started %d times, want 1", n)
+	}
+}
+
+func TestSuperviseComponentPanicIsFatal(t *testing.T) {
+	c := &fakeComponent{
+		startFunc: func(context.Context, int) error {
+			panic("boom")
+		},
+	}
+	if err := superviseComponent(context.Background(), c, defaultComponentOptions()); err == nil {
+		t.Fatal("superviseComponent() = nil, want the recovered panic")
+	}
+}
+
+func TestSuperviseComponentRestartBackoff(t *testing.T) {
+	const (
+		minBackoff = 20 * time.Millisecond
+		maxBackoff = 50 * time.Millisecond
+		failures   = 4
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &fakeComponent{
+		startFunc: func(ctx context.Context, n int) error {
+			switch {
+			case n < failures-1:
+				return errComponentFailed
+			case n == failures-1:
+				panic("boom")
+			}
+			// Recovered, runs until ctx is done.
+			cancel()
+			<-ctx.Done()
+			return nil
+		},
+	}
+	opts := defaultComponentOptions()
+	WithFailurePolicy(FailurePolicyRestart)(opts)
+	WithRestartBackoff(minBackoff, maxBackoff)(opts)
+	if err := superviseComponent(ctx, c, opts); err != nil {
+		t.Fatalf("superviseComponent() = %v, want nil", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.starts) != failures+1 {
+		t.Fatalf("started %d times, want %d", len(c.starts), failures+1)
+	}
+	// The backoff doubles from minBackoff and is capped at maxBackoff.
+	want := []time.Duration{minBackoff, 2 * minBackoff, maxBackoff, maxBackoff}
+	for i, w := range want {
+		if gap := c.starts[i+1].Sub(c.starts[i]); gap < w {
+			t.Errorf("wait before restart #%d = %s, want at least %s", i+1, gap, w)
+		}
+	}
+}
+
+func TestSuperviseComponentRestartCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &fakeComponent{
+		startFunc: func(context.Context, int) error {
+			return errComponentFailed
+		},
+	}
+	opts := defaultComponentOptions()
+	WithFailurePolicy(FailurePolicyRestart)(opts)
+	WithRestartBackoff(time.Hour, time.Hour)(opts)
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- superviseComponent(ctx, c, opts)
+	}()
+	// Cancel once superviseComponent is waiting for the restart.
+	for c.numStarts() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-doneCh:
+		if err != nil {
+			t.Fatalf("superviseComponent() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("superviseComponent() does not return when ctx is done during the backoff")
+	}
+}